@@ -0,0 +1,264 @@
+// Package client calls API of homecontroller server. Endpoints and bodies
+// are described by OpenAPI document the server serves at /openapi.json.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Error is returned when server responds with error status. Code is HTTP
+// status code, Fields names invalid fields or reason of conflict.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Fields  string `json:"fields"`
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("request failed with status code %d", e.Code)
+	}
+
+	return fmt.Sprintf("request failed with status code %d: %s", e.Code, e.Message)
+}
+
+type Client struct {
+	// BaseURL is address of server, e.g. http://localhost:8080
+	BaseURL string
+	// Token is sent as bearer token when not empty
+	Token      string
+	HTTPClient *http.Client
+}
+
+func New(baseURL string, token string) *Client {
+	return &Client{
+		BaseURL:    baseURL,
+		Token:      token,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Wake sends magic packet described by request.
+func (c *Client) Wake(ctx context.Context, request WakeRequest) error {
+	return c.do(ctx, "POST", "/wake", nil, request, nil)
+}
+
+// Halt halts host described by request via SSH.
+func (c *Client) Halt(ctx context.Context, request HaltRequest) error {
+	return c.do(ctx, "POST", "/halt", nil, request, nil)
+}
+
+func (c *Client) Status(ctx context.Context, host string) (Status, error) {
+	var status Status
+	err := c.do(ctx, "GET", "/status/"+url.PathEscape(host), nil, nil, &status)
+	return status, err
+}
+
+func (c *Client) Version(ctx context.Context) (Version, error) {
+	var version Version
+	err := c.do(ctx, "GET", "/version", nil, nil, &version)
+	return version, err
+}
+
+// Targets lists targets registered on server.
+func (c *Client) Targets(ctx context.Context) ([]Target, error) {
+	var targets []Target
+	err := c.do(ctx, "GET", "/targets", nil, nil, &targets)
+	return targets, err
+}
+
+// WakeTarget wakes target registered on server and waits for its hooks.
+func (c *Client) WakeTarget(ctx context.Context, id string) (ActionResult, error) {
+	var result ActionResult
+	err := c.do(ctx, "POST", targetPath(id, "wake"), nil, nil, &result)
+	return result, err
+}
+
+// HaltTarget halts target registered on server and waits for its hooks.
+func (c *Client) HaltTarget(ctx context.Context, id string) (ActionResult, error) {
+	var result ActionResult
+	err := c.do(ctx, "POST", targetPath(id, "halt"), nil, nil, &result)
+	return result, err
+}
+
+// RebootTarget reboots target registered on server, hooks are not run.
+func (c *Client) RebootTarget(ctx context.Context, id string) (ActionResult, error) {
+	var result ActionResult
+	err := c.do(ctx, "POST", targetPath(id, "reboot"), nil, nil, &result)
+	return result, err
+}
+
+// Exec runs command configured on target registered on server. Non-zero
+// exit code of the command is not an error.
+func (c *Client) Exec(ctx context.Context, id string, name string) (ExecResult, error) {
+	var result ExecResult
+	err := c.do(ctx, "POST", targetPath(id, "exec", name), nil, nil, &result)
+	return result, err
+}
+
+// StartWakeTarget wakes target as job, progress is followed by Job.
+func (c *Client) StartWakeTarget(ctx context.Context, id string) (Job, error) {
+	return c.startJob(ctx, targetPath(id, "wake"))
+}
+
+// StartHaltTarget halts target as job, progress is followed by Job.
+func (c *Client) StartHaltTarget(ctx context.Context, id string) (Job, error) {
+	return c.startJob(ctx, targetPath(id, "halt"))
+}
+
+// StartRebootTarget reboots target as job, progress is followed by Job.
+func (c *Client) StartRebootTarget(ctx context.Context, id string) (Job, error) {
+	return c.startJob(ctx, targetPath(id, "reboot"))
+}
+
+// StartExec runs command as job, progress is followed by Job.
+func (c *Client) StartExec(ctx context.Context, id string, name string) (Job, error) {
+	return c.startJob(ctx, targetPath(id, "exec", name))
+}
+
+func (c *Client) Jobs(ctx context.Context) ([]Job, error) {
+	var jobs []Job
+	err := c.do(ctx, "GET", "/jobs", nil, nil, &jobs)
+	return jobs, err
+}
+
+func (c *Client) Job(ctx context.Context, id string) (Job, error) {
+	var job Job
+	err := c.do(ctx, "GET", "/jobs/"+url.PathEscape(id), nil, nil, &job)
+	return job, err
+}
+
+// CancelJob requests cancellation, job is cancelled once it reaches step
+// which can be interrupted.
+func (c *Client) CancelJob(ctx context.Context, id string) (Job, error) {
+	var job Job
+	err := c.do(ctx, "DELETE", "/jobs/"+url.PathEscape(id), nil, nil, &job)
+	return job, err
+}
+
+// KnownHost returns host key presented to server by SSH server on host.
+// Nil port means the default one.
+func (c *Client) KnownHost(ctx context.Context, host string, port *int) (HostKey, error) {
+	var hostKey HostKey
+	err := c.do(ctx, "GET", "/known-hosts/"+url.PathEscape(host), portQuery(port), nil, &hostKey)
+	return hostKey, err
+}
+
+func (c *Client) TrustKnownHost(ctx context.Context, request TrustHostKeyRequest) (HostKey, error) {
+	var hostKey HostKey
+	err := c.do(ctx, "POST", "/known-hosts", nil, request, &hostKey)
+	return hostKey, err
+}
+
+func (c *Client) RemoveKnownHost(ctx context.Context, host string, port *int) error {
+	return c.do(ctx, "DELETE", "/known-hosts/"+url.PathEscape(host), portQuery(port), nil, nil)
+}
+
+func (c *Client) startJob(ctx context.Context, path string) (Job, error) {
+	var job Job
+	err := c.do(ctx, "POST", path, url.Values{"async": {"1"}}, nil, &job)
+	return job, err
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body interface{}, result interface{}) error {
+	fullUrl, err := url.JoinPath(c.BaseURL, path)
+	if err != nil {
+		return fmt.Errorf("couldnt build url, %v", err)
+	}
+
+	if len(query) > 0 {
+		fullUrl += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		bts, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("couldnt marshal body, %v", err)
+		}
+
+		reader = bytes.NewReader(bts)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, fullUrl, reader)
+	if err != nil {
+		return err
+	}
+
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	if reader != nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		respErr := &Error{}
+		if decodeBody(resp, respErr) != nil {
+			respErr.Message = ""
+		}
+		respErr.Code = resp.StatusCode
+		return respErr
+	}
+
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	if err := decodeBody(resp, result); err != nil {
+		return fmt.Errorf("couldnt decode response body, %v", err)
+	}
+
+	return nil
+}
+
+func decodeBody(response *http.Response, v interface{}) error {
+	contentType := response.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return err
+	}
+
+	if mediaType != "application/json" {
+		return fmt.Errorf("unexpected content type '%s', expected: 'application/json'", contentType)
+	}
+
+	return json.NewDecoder(response.Body).Decode(v)
+}
+
+func targetPath(id string, segments ...string) string {
+	path := "/targets/" + url.PathEscape(id)
+	for _, segment := range segments {
+		path += "/" + url.PathEscape(segment)
+	}
+
+	return path
+}
+
+func portQuery(port *int) url.Values {
+	if port == nil {
+		return nil
+	}
+
+	return url.Values{"port": {strconv.Itoa(*port)}}
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/websocket"
+)
+
+// StatusUpdate is online status of target sent by status stream. Target is
+// the value it was subscribed with.
+type StatusUpdate struct {
+	Target string
+	Status Status
+}
+
+type streamMessage struct {
+	Version int      `json:"v"`
+	Type    string   `json:"type"`
+	Target  string   `json:"target,omitempty"`
+	Targets []string `json:"targets,omitempty"`
+	Status  *Status  `json:"status,omitempty"`
+	Error   *Error   `json:"error,omitempty"`
+}
+
+// StatusStream subscribes to status of targets via websocket. Targets are ids
+// of targets registered on server or any hosts. Channel is closed when ctx is
+// cancelled or connection is lost, updates are sent only on change.
+func (c *Client) StatusStream(ctx context.Context, targets []string) (<-chan StatusUpdate, error) {
+	wsUrl, err := url.JoinPath(c.BaseURL, "/status-stream")
+	if err != nil {
+		return nil, fmt.Errorf("couldnt build url, %v", err)
+	}
+
+	// websocket scheme follows scheme of server, origin is server itself
+	origin := c.BaseURL
+	wsUrl = strings.Replace(wsUrl, "http", "ws", 1)
+
+	wsConfig, err := websocket.NewConfig(wsUrl, origin)
+	if err != nil {
+		return nil, fmt.Errorf("couldnt build websocket config, %v", err)
+	}
+
+	if c.Token != "" {
+		wsConfig.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	conn, err := wsConfig.DialContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = websocket.JSON.Send(conn, streamMessage{Version: 1, Type: "subscribe", Targets: targets})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	updates := make(chan StatusUpdate)
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	go func() {
+		defer close(updates)
+
+		for {
+			var msg streamMessage
+			if err := websocket.JSON.Receive(conn, &msg); err != nil {
+				return
+			}
+
+			if msg.Type != "status" || msg.Status == nil {
+				continue
+			}
+
+			select {
+			case updates <- StatusUpdate{Target: msg.Target, Status: *msg.Status}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return updates, nil
+}
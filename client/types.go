@@ -0,0 +1,135 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Types mirror JSON bodies of the API as described by /openapi.json.
+
+type BroadcastAddress struct {
+	Ip   string `json:"ip"`
+	Port int    `json:"port"`
+}
+
+type WakeRequest struct {
+	Mac              string              `json:"mac"`
+	BroadcastAddress []*BroadcastAddress `json:"addresses,omitempty"`
+
+	// Host and VerifyTimeout (in seconds) make server verify that the
+	// target came online after the magic packet was sent.
+	Host          string `json:"host,omitempty"`
+	VerifyTimeout int    `json:"verify_timeout,omitempty"`
+}
+
+type PrivateKey struct {
+	Path       string `json:"path"`
+	Passphrase string `json:"passphrase"`
+}
+
+type JumpHost struct {
+	Host        string      `json:"host"`
+	User        string      `json:"user,omitempty"`
+	Port        *int        `json:"port,omitempty"`
+	Password    string      `json:"password,omitempty"`
+	PrivateKey  *PrivateKey `json:"private_key,omitempty"`
+	Certificate string      `json:"certificate,omitempty"`
+	HostKey     string      `json:"host_key,omitempty"`
+}
+
+// HaltRequest describes SSH connection to halted host, credentials may be
+// omitted when server's ssh config or agent provides them.
+type HaltRequest struct {
+	User        string      `json:"user,omitempty"`
+	Host        string      `json:"host"`
+	Port        *int        `json:"port,omitempty"`
+	Password    string      `json:"password,omitempty"`
+	PrivateKey  *PrivateKey `json:"private_key,omitempty"`
+	Certificate string      `json:"certificate,omitempty"`
+	HostKey     string      `json:"host_key,omitempty"`
+	ProxyJump   []JumpHost  `json:"proxy_jump,omitempty"`
+}
+
+type Status struct {
+	IsOnline bool `json:"is_online"`
+}
+
+type Version struct {
+	Version string `json:"version"`
+}
+
+// Target is target registered on server.
+type Target struct {
+	Id       string   `json:"id"`
+	Host     string   `json:"host"`
+	Mac      string   `json:"mac"`
+	Commands []string `json:"commands"`
+}
+
+type ExecResult struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+}
+
+type HookResult struct {
+	Hook  string `json:"hook"`
+	Local bool   `json:"local"`
+	ExecResult
+	Error string `json:"error,omitempty"`
+}
+
+type ActionResult struct {
+	Target string       `json:"target"`
+	Hooks  []HookResult `json:"hooks,omitempty"`
+}
+
+const (
+	JobStatePending   = "pending"
+	JobStateRunning   = "running"
+	JobStateSucceeded = "succeeded"
+	JobStateFailed    = "failed"
+	JobStateCancelled = "cancelled"
+)
+
+type Job struct {
+	Id       string          `json:"id"`
+	Action   string          `json:"action"`
+	Target   string          `json:"target"`
+	State    string          `json:"state"`
+	Progress string          `json:"progress,omitempty"`
+	Created  time.Time       `json:"created"`
+	Finished *time.Time      `json:"finished,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+	Error    *Error          `json:"error,omitempty"`
+}
+
+// ActionResult decodes result of finished wake or halt job.
+func (j *Job) ActionResult() (ActionResult, error) {
+	var result ActionResult
+	err := json.Unmarshal(j.Result, &result)
+	return result, err
+}
+
+// ExecResult decodes result of finished exec job.
+func (j *Job) ExecResult() (ExecResult, error) {
+	var result ExecResult
+	err := json.Unmarshal(j.Result, &result)
+	return result, err
+}
+
+type HostKey struct {
+	Host        string `json:"host"`
+	Type        string `json:"type"`
+	Fingerprint string `json:"fingerprint"`
+	Known       bool   `json:"known"`
+	Changed     bool   `json:"changed"`
+}
+
+// TrustHostKeyRequest confirms fingerprint shown to user, server records
+// the key only when it receives the same key again.
+type TrustHostKeyRequest struct {
+	Host        string `json:"host"`
+	Port        *int   `json:"port,omitempty"`
+	Fingerprint string `json:"fingerprint"`
+}
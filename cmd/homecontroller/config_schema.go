@@ -0,0 +1,19 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"homecontroller/config"
+)
+
+// handleConfigSchemaCommand prints JSON Schema of config file. Editors with
+// YAML language server use it when config file starts with
+// "# yaml-language-server: $schema=PATH".
+func handleConfigSchemaCommand() {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(config.Schema()); err != nil {
+		log.Fatalf("Could not write schema: %v", err)
+	}
+}
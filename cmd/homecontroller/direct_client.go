@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"homecontroller/config"
+	"homecontroller/controller"
+	"homecontroller/probing"
+)
+
+func handleRunCommand(targetId string, command string, args []string) {
+	if targetId == "" {
+		log.Fatal("missing flag --target")
+		return
+	}
+
+	localConfig, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+
+	targetConfig := config.TargetById(localConfig.RunTargets, targetId)
+	if targetConfig == nil {
+		log.Fatalf("Run target '%s' not found", targetId)
+		return
+	}
+
+	switch command {
+	case "wake":
+		handleRunWake(targetConfig)
+		break
+	case "halt":
+		handleRunHalt(targetConfig)
+		break
+	case "reboot":
+		handleRunReboot(targetConfig)
+		break
+	case "status":
+		handleRunStatus(targetConfig)
+		break
+	case "exec":
+		if len(args) < 1 {
+			log.Fatal("command exec must have an argument: homecontroller --target=[target] run exec [NAME]")
+			return
+		}
+		handleRunExec(targetConfig, args[0])
+		break
+	case "status-stream":
+	default:
+		log.Fatalf("Unknown command '%s'", command)
+		break
+	}
+}
+
+func handleRunWake(targetConfig *config.TargetConfiguration) {
+	result, err := controller.Wake(context.Background(), targetConfig, promptFromTerminal)
+	printHookResults(result.Hooks)
+	if err != nil {
+		log.Fatalf("Could not send magic packet to target %s: %v", targetConfig.Id, err)
+	}
+
+	fmt.Printf("Magic packet sent to '%s' to mac '%s'\n", targetConfig.Id, targetConfig.Mac)
+}
+
+func handleRunHalt(targetConfig *config.TargetConfiguration) {
+	result, err := controller.Halt(context.Background(), targetConfig, promptFromTerminal)
+	printHookResults(result.Hooks)
+	if err != nil {
+		log.Fatalf("Could not send halt command via ssh to target %s: %v", targetConfig.Id, err)
+	}
+
+	fmt.Printf("Halt command sent to '%s'\n", targetConfig.Id)
+}
+
+func handleRunReboot(targetConfig *config.TargetConfiguration) {
+	_, err := controller.Reboot(context.Background(), targetConfig, promptFromTerminal)
+	if err != nil {
+		log.Fatalf("Could not send reboot command via ssh to target %s: %v", targetConfig.Id, err)
+	}
+
+	fmt.Printf("Reboot command sent to '%s'\n", targetConfig.Id)
+}
+
+func handleRunStatus(targetConfig *config.TargetConfiguration) {
+	isOnline, err := probing.Online(context.Background(), targetConfig.Host, targetConfig.ProbeConfig())
+	if err != nil {
+		log.Errorf("Could not check online status of target %s: %v", targetConfig.Id, err)
+	}
+
+	printStatusResponse(targetConfig.Id, isOnline)
+}
+
+func handleRunExec(targetConfig *config.TargetConfiguration, name string) {
+	result, err := controller.Exec(context.Background(), targetConfig, name, promptFromTerminal)
+	printExecResult(result)
+	if err != nil {
+		log.Fatalf("Could not run command %s on target %s: %v", name, targetConfig.Id, err)
+	}
+
+	os.Exit(result.ExitCode)
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"homecontroller/client"
+	"homecontroller/config"
+	"homecontroller/probing"
+	"homecontroller/sshctl"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const doctorTimeout = 5 * time.Second
+
+// errDoctorSkip marks check which couldn't run because of failed
+// prerequisite or because it doesn't apply to the configuration.
+type errDoctorSkip struct {
+	reason string
+}
+
+func (e errDoctorSkip) Error() string {
+	return e.reason
+}
+
+type doctorCheck struct {
+	name string
+	run  func() (string, error)
+}
+
+// runDoctorChecks runs checks in order and prints result line of each,
+// returns false when any of the checks failed.
+func runDoctorChecks(checks []doctorCheck) bool {
+	ok := true
+	for _, check := range checks {
+		detail, err := check.run()
+
+		var skip errDoctorSkip
+		switch {
+		case errors.As(err, &skip):
+			fmt.Printf("[SKIP] %s: %s\n", check.name, skip.reason)
+		case err != nil:
+			ok = false
+			fmt.Printf("[FAIL] %s: %v\n", check.name, err)
+		default:
+			fmt.Printf("[PASS] %s: %s\n", check.name, detail)
+		}
+	}
+
+	return ok
+}
+
+func handleDoctorCommand(remoteId string, targetId string) {
+	capabilities := probing.DetectCapabilities()
+	fmt.Printf("Probe mode: %s\n", capabilities.Mode)
+	fmt.Printf("  %s\n", capabilities.Reason)
+
+	if targetId == "" && remoteId == "" {
+		return
+	}
+
+	localConfig, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+
+	var checks []doctorCheck
+	if remoteId != "" {
+		remoteConfig := localConfig.RemoteById(remoteId)
+		if remoteConfig == nil {
+			log.Fatalf("Configuration '%s' not found in config file", remoteId)
+			return
+		}
+
+		checks = remoteDoctorChecks(remoteConfig)
+	} else {
+		targetConfig := config.TargetById(localConfig.RunTargets, targetId)
+		if targetConfig == nil {
+			log.Fatalf("Run target '%s' not found", targetId)
+			return
+		}
+
+		checks = targetDoctorChecks(targetConfig)
+	}
+
+	fmt.Println()
+	if !runDoctorChecks(checks) {
+		os.Exit(1)
+	}
+}
+
+func targetDoctorChecks(target *config.TargetConfiguration) []doctorCheck {
+	var ip net.IP
+	var isOnline bool
+	var sshReachable bool
+	dest := target.SshDestination().Resolve()
+
+	requireIp := func() error {
+		if ip == nil {
+			return errDoctorSkip{"host was not resolved"}
+		}
+		return nil
+	}
+
+	return []doctorCheck{
+		{"DNS resolution", func() (string, error) {
+			var err error
+			ip, err = probing.ResolveIPv4(target.Host)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%s resolves to %s", target.Host, ip), nil
+		}},
+		{"Reachability", func() (string, error) {
+			if err := requireIp(); err != nil {
+				return "", err
+			}
+
+			var err error
+			isOnline, err = probing.Online(context.Background(), target.Host, target.ProbeConfig())
+			if err != nil {
+				return "", err
+			}
+			if !isOnline {
+				return "", fmt.Errorf("host does not respond to %s probe", target.ProbeConfig().ResolvedMode())
+			}
+			return fmt.Sprintf("host responds to %s probe", target.ProbeConfig().ResolvedMode()), nil
+		}},
+		{"MAC address", func() (string, error) {
+			if err := requireIp(); err != nil {
+				return "", err
+			}
+
+			mac, err := net.ParseMAC(string(target.Mac))
+			if err != nil {
+				return "", fmt.Errorf("configured mac '%s' is invalid, %v", target.Mac, err)
+			}
+
+			entry, err := probing.LookupArpEntry(ip)
+			if err != nil {
+				return "", err
+			}
+			if entry == nil {
+				return "", fmt.Errorf("no neighbour entry for %s, target is offline or not on local network", ip)
+			}
+
+			neighbourMac, err := net.ParseMAC(entry.Mac)
+			if err != nil || neighbourMac.String() != mac.String() {
+				return "", fmt.Errorf("configured %s, but neighbour table has %s on %s", mac, entry.Mac, entry.Device)
+			}
+			return fmt.Sprintf("%s matches neighbour entry on %s", mac, entry.Device), nil
+		}},
+		{"Broadcast route", func() (string, error) {
+			return checkBroadcastRoute(target, ip)
+		}},
+		{"SSH host key", func() (string, error) {
+			detail, err := checkSshHostKey(dest)
+			sshReachable = err == nil
+			return detail, err
+		}},
+		{"SSH authentication", func() (string, error) {
+			if !sshReachable {
+				return "", errDoctorSkip{"host key was not verified"}
+			}
+
+			client, err := sshctl.Dial(context.Background(), dest, promptFromTerminal)
+			if err != nil {
+				sshReachable = false
+				return "", err
+			}
+			client.Close()
+			return fmt.Sprintf("authenticated as %s", dest.User), nil
+		}},
+		{"Passwordless halt", func() (string, error) {
+			if !sshReachable {
+				return "", errDoctorSkip{"ssh authentication failed"}
+			}
+			if dest.User == "root" {
+				return "", errDoctorSkip{"connected as root, sudo is not used"}
+			}
+
+			client, err := sshctl.Dial(context.Background(), dest, promptFromTerminal)
+			if err != nil {
+				return "", err
+			}
+			defer client.Close()
+
+			session, err := client.NewSession()
+			if err != nil {
+				return "", err
+			}
+			defer session.Close()
+
+			output, err := session.CombinedOutput("sudo -n -l halt -p")
+			if err != nil {
+				return "", fmt.Errorf("sudo -n halt -p is not permitted without password: %s", strings.TrimSpace(string(output)))
+			}
+			return fmt.Sprintf("sudo permits %s", strings.TrimSpace(string(output))), nil
+		}},
+	}
+}
+
+// checkBroadcastRoute reports interface through which magic packets leave
+// and whether target is on the network of that interface.
+func checkBroadcastRoute(target *config.TargetConfiguration, targetIp net.IP) (string, error) {
+	destinations := []string{"255.255.255.255"}
+	if len(target.BroadcastAddress) > 0 {
+		destinations = nil
+		for _, address := range target.BroadcastAddress {
+			destinations = append(destinations, string(address.Ip))
+		}
+	}
+
+	var details []string
+	for _, destination := range destinations {
+		destinationIp := net.ParseIP(destination)
+		if destinationIp == nil {
+			return "", fmt.Errorf("invalid broadcast address '%s'", destination)
+		}
+
+		// limited broadcast follows route to the target itself
+		routeIp := destinationIp
+		if destinationIp.Equal(net.IPv4bcast) && targetIp != nil {
+			routeIp = targetIp
+		}
+
+		iface, network, err := outgoingInterface(routeIp)
+		if err != nil {
+			return "", fmt.Errorf("no route for %s, %v", destination, err)
+		}
+
+		if targetIp != nil && !network.Contains(targetIp) {
+			return "", fmt.Errorf("packets for %s leave via %s (%s), which does not contain %s", destination, iface.Name, network, targetIp)
+		}
+
+		details = append(details, fmt.Sprintf("%s via %s (%s)", destination, iface.Name, network))
+	}
+
+	return strings.Join(details, ", "), nil
+}
+
+func outgoingInterface(ip net.IP) (*net.Interface, *net.IPNet, error) {
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: ip, Port: 9})
+	if err != nil {
+		return nil, nil, err
+	}
+	localIp := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range ifaces {
+		addrs, err := ifaces[i].Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			if network, ok := addr.(*net.IPNet); ok && network.IP.Equal(localIp) {
+				return &ifaces[i], &net.IPNet{IP: network.IP.Mask(network.Mask), Mask: network.Mask}, nil
+			}
+		}
+	}
+
+	return nil, nil, fmt.Errorf("no interface has address %s", localIp)
+}
+
+// checkSshHostKey verifies host key of server against pinned fingerprint or
+// known_hosts.
+func checkSshHostKey(dest sshctl.Destination) (string, error) {
+	hostKeyCallback, err := sshctl.HostKeyCallback(dest)
+	if err != nil {
+		return "", err
+	}
+
+	addr := dest.Addr()
+	key, err := sshctl.FetchHostKey(context.Background(), dest)
+	if err != nil {
+		return "", err
+	}
+
+	tcpAddr, _ := net.ResolveTCPAddr("tcp", addr)
+	if err := hostKeyCallback(addr, tcpAddr, key); err != nil {
+		return "", err
+	}
+
+	if dest.HostKey != "" {
+		return fmt.Sprintf("%s matches pinned host_key", ssh.FingerprintSHA256(key)), nil
+	}
+	return fmt.Sprintf("%s matches known_hosts", ssh.FingerprintSHA256(key)), nil
+}
+
+func remoteDoctorChecks(remote *config.RemoteConfiguration) []doctorCheck {
+	var remoteUrl *url.URL
+	api, tokenErr := newRemoteClient(remote)
+	if tokenErr != nil {
+		api = client.New(remote.Host, "")
+	}
+	api.HTTPClient = &http.Client{Timeout: doctorTimeout}
+
+	requireUrl := func() error {
+		if remoteUrl == nil {
+			return errDoctorSkip{"remote url is invalid"}
+		}
+		return nil
+	}
+
+	return []doctorCheck{
+		{"Remote URL", func() (string, error) {
+			var err error
+			remoteUrl, err = url.Parse(remote.Host)
+			if err != nil {
+				return "", err
+			}
+			if remoteUrl.Scheme != "http" && remoteUrl.Scheme != "https" {
+				remoteUrl = nil
+				return "", fmt.Errorf("unsupported scheme in '%s'", remote.Host)
+			}
+			return remote.Host, nil
+		}},
+		{"TLS", func() (string, error) {
+			if err := requireUrl(); err != nil {
+				return "", err
+			}
+			if remoteUrl.Scheme != "https" {
+				return "", errDoctorSkip{"remote uses plain HTTP"}
+			}
+
+			addr := remoteUrl.Host
+			if remoteUrl.Port() == "" {
+				addr = net.JoinHostPort(remoteUrl.Hostname(), "443")
+			}
+
+			dialer := &net.Dialer{Timeout: doctorTimeout}
+			conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: remoteUrl.Hostname()})
+			if err != nil {
+				return "", err
+			}
+			defer conn.Close()
+
+			cert := conn.ConnectionState().PeerCertificates[0]
+			return fmt.Sprintf("certificate for %s valid until %s", cert.Subject.CommonName, cert.NotAfter.Format(time.DateOnly)), nil
+		}},
+		{"Auth token", func() (string, error) {
+			if err := requireUrl(); err != nil {
+				return "", err
+			}
+			if tokenErr != nil {
+				return "", tokenErr
+			}
+
+			_, err := api.Version(context.Background())
+			var apiErr *client.Error
+			if errors.As(err, &apiErr) {
+				if apiErr.Code == http.StatusForbidden || apiErr.Code == http.StatusUnauthorized {
+					if remote.AuthToken == "" {
+						return "", errors.New("server requires token, but none is configured")
+					}
+					return "", errors.New("token was rejected by server")
+				}
+			}
+			if err != nil {
+				return "", err
+			}
+			if remote.AuthToken == "" {
+				return "server does not require token", nil
+			}
+			return "token accepted", nil
+		}},
+		{"Server version", func() (string, error) {
+			if err := requireUrl(); err != nil {
+				return "", err
+			}
+
+			versionData, err := api.Version(context.Background())
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("server %s, client %s", versionData.Version, version), nil
+		}},
+	}
+}
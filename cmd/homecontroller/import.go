@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"homecontroller/config"
+	"homecontroller/inventory"
+)
+
+// diffContextLines is number of unchanged lines shown around changes.
+const diffContextLines = 2
+
+// handleImportCommand builds run targets from inventories of other tools and
+// writes them into config file after showing diff. Ansible inventories add
+// targets, ethers and ssh_config fill MAC addresses and SSH settings of added
+// and already configured targets.
+func handleImportCommand(args []string) {
+	var sources []inventory.Source
+	for _, arg := range args {
+		source, err := inventory.ParseSource(arg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		sources = append(sources, source)
+	}
+
+	localConfig, err := config.Load()
+	if errors.Is(err, config.ErrNotFound) {
+		localConfig = &config.LocalConfiguration{}
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	configured := localConfig.RunTargets
+	targets := slices.Clone(configured)
+	// sources adding targets go first, so that the others can fill them
+	slices.SortStableFunc(sources, func(a, b inventory.Source) int {
+		if a.Creates() == b.Creates() {
+			return 0
+		}
+		if a.Creates() {
+			return -1
+		}
+		return 1
+	})
+	for _, source := range sources {
+		entries, err := source.Read()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if source.Creates() {
+			targets = inventory.Merge(targets, entries)
+		} else {
+			inventory.Enrich(targets, entries)
+		}
+	}
+
+	files, err := applyImport(localConfig, configured, targets)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var changed []*config.File
+	for _, file := range files {
+		if !file.Changed() {
+			continue
+		}
+
+		after, err := file.Bytes()
+		if err != nil {
+			log.Fatal(err)
+		}
+		printDiff(file.Path, file.Original, after)
+		changed = append(changed, file)
+	}
+
+	if len(changed) == 0 {
+		fmt.Println("Config is up to date, there is nothing to import.")
+		return
+	}
+
+	if !confirm("Write changes to config?") {
+		fmt.Println("Nothing was written.")
+		return
+	}
+
+	for _, file := range changed {
+		if err := file.Save(); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Written %s\n", file.Path)
+	}
+}
+
+// applyImport edits config files, new targets are added to the main config
+// file and changed targets are filled in file which defines them.
+func applyImport(localConfig *config.LocalConfiguration, configured []config.TargetConfiguration, targets []config.TargetConfiguration) ([]*config.File, error) {
+	var files []*config.File
+	for _, path := range localConfig.Sources {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			continue
+		}
+
+		file, err := config.OpenFile(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	if len(files) == 0 {
+		file, err := config.OpenFile(config.SearchPaths()[0])
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	for i, target := range targets {
+		if i >= len(configured) {
+			if err := files[0].AddTarget(target); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		patch, ok := importedFields(configured[i], target)
+		if !ok {
+			continue
+		}
+
+		index := slices.IndexFunc(files, func(file *config.File) bool {
+			return file.HasTarget(target.Id)
+		})
+		if index < 0 {
+			log.Warningf("Could not find definition of target '%s' in config files, it is not updated", target.Id)
+			continue
+		}
+
+		if err := files[index].FillTarget(patch); err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// importedFields returns target with id and fields filled by import only,
+// false when import filled nothing.
+func importedFields(before config.TargetConfiguration, after config.TargetConfiguration) (config.TargetConfiguration, bool) {
+	patch := config.TargetConfiguration{Id: after.Id}
+	if before.Mac != after.Mac {
+		patch.Mac = after.Mac
+	}
+	if before.Ssh.User != after.Ssh.User {
+		patch.Ssh.User = after.Ssh.User
+	}
+	if before.Ssh.Port != after.Ssh.Port {
+		patch.Ssh.Port = after.Ssh.Port
+	}
+	if before.Ssh.PrivateKey.Path != after.Ssh.PrivateKey.Path {
+		patch.Ssh.PrivateKey.Path = after.Ssh.PrivateKey.Path
+	}
+	if !reflect.DeepEqual(before.Ssh.ProxyJump, after.Ssh.ProxyJump) {
+		patch.Ssh.ProxyJump = after.Ssh.ProxyJump
+	}
+
+	return patch, !reflect.DeepEqual(patch, config.TargetConfiguration{Id: after.Id})
+}
+
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// printDiff prints changed lines of file with few lines of context.
+func printDiff(path string, before []byte, after []byte) {
+	a := splitLines(before)
+	b := splitLines(after)
+
+	// longest common subsequence of lines from i and j on
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+
+	fmt.Printf("--- %s\n+++ %s\n", path, path)
+	skipped := false
+	for k, line := range lines {
+		near := false
+		for c := max(0, k-diffContextLines); c <= min(len(lines)-1, k+diffContextLines); c++ {
+			if lines[c].op != ' ' {
+				near = true
+				break
+			}
+		}
+
+		if !near {
+			skipped = true
+			continue
+		}
+		if skipped {
+			fmt.Println("@@")
+			skipped = false
+		}
+		fmt.Printf("%c%s\n", line.op, line.text)
+	}
+}
+
+func splitLines(bts []byte) []string {
+	text := strings.TrimSuffix(string(bts), "\n")
+	if text == "" {
+		return nil
+	}
+
+	return strings.Split(text, "\n")
+}
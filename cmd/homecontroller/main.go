@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"homecontroller/config"
+	"homecontroller/controller"
+	"homecontroller/probing"
+	"homecontroller/secret"
+	"homecontroller/server"
+	"homecontroller/sshctl"
+)
+
+// version is set at build time via -ldflags "-X main.version=..."
+var version = "dev"
+
+var configFlag = flag.String("config", "", "Path to config file, HOMECONTROLLER_CONFIG is used when empty, then the first existing of XDG config dir, ~/.homecontroller and /etc/homecontroller")
+var httpAuthTokenFlag = flag.String("auth_token", "", "Token that must be provided in HTTP header to access API, secret reference like env:VAR keeps it out of process list, HOMECONTROLLER_AUTH_TOKEN is used when empty")
+var httpAddrFlag = flag.String("http_addr", ":80", "Address to which HTTP server should bind")
+var httpsAddrFlag = flag.String("https_addr", ":443", "Address to which HTTPS server should bind")
+var httpsCertFlag = flag.String("https_cert", "", "Path to file containing HTTPS certificate")
+var httpsKeyFlag = flag.String("https_key", "", "Path to file containing HTTPS key")
+var dashboardFlag = flag.Bool("dashboard", false, "Serve web dashboard on HTTP server, login uses auth_token")
+var mqttBrokerFlag = flag.String("mqtt_broker", "", "Address of MQTT broker to publish targets to, e.g. tcp://localhost:1883")
+var mqttClientIdFlag = flag.String("mqtt_client_id", "homecontroller", "Client identifier used when connecting to MQTT broker")
+var mqttUserFlag = flag.String("mqtt_user", "", "User for MQTT broker")
+var mqttPasswordFlag = flag.String("mqtt_password", "", "Password for MQTT broker, may be secret reference, HOMECONTROLLER_MQTT_PASSWORD is used when empty")
+var mqttTopicPrefixFlag = flag.String("mqtt_topic_prefix", "homecontroller", "Prefix of MQTT state and command topics")
+var mqttDiscoveryPrefixFlag = flag.String("mqtt_discovery_prefix", "homeassistant", "Home Assistant MQTT discovery prefix")
+var sshIdleTimeoutFlag = flag.Duration("ssh_idle_timeout", sshctl.DefaultIdleTimeout, "How long idle SSH connections are kept for reuse, 0 disables reuse")
+var jobRetentionFlag = flag.Duration("job_retention", server.DefaultJobRetention, "How long finished asynchronous jobs are kept")
+var adhocSshFlag = flag.Bool("adhoc_ssh", false, "Let /halt and known hosts API use ~/.ssh/config, ssh-agent and known_hosts of server also for hosts which are not registered targets")
+var knownHostsFlag = flag.String("known_hosts", "", "Path to SSH known_hosts file, defaults to ~/.ssh/known_hosts")
+var cmdTargetFlag = flag.String("target", "", "Identifier of target in config to run command for")
+var cmdFollowFlag = flag.Bool("follow", false, "Run remote-run command as job on remote server and follow its progress")
+var cmdRemoteFlag = flag.String("remote", "", "Identifier of remote server, via which commands should run")
+
+func failWithUsage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+	fmt.Fprintln(flag.CommandLine.Output(), " Commands:")
+	fmt.Fprintln(flag.CommandLine.Output(), "  http: Start HTTP server")
+	fmt.Fprintln(flag.CommandLine.Output(), "  run: Runs command directly")
+	fmt.Fprintln(flag.CommandLine.Output(), "  remote-run: Runs command via remote server")
+	fmt.Fprintln(flag.CommandLine.Output(), "  ssh trust: Records SSH host key of target after confirmation")
+	fmt.Fprintln(flag.CommandLine.Output(), "  doctor: Diagnoses environment and target configuration")
+	fmt.Fprintln(flag.CommandLine.Output(), "  tui: Shows run and remote targets with live status in terminal")
+	fmt.Fprintln(flag.CommandLine.Output(), "  secret set: Stores secret in backend of reference, e.g. keyring:homecontroller/nas")
+	fmt.Fprintln(flag.CommandLine.Output(), "  config schema: Prints JSON Schema of config file for editors")
+	fmt.Fprintln(flag.CommandLine.Output(), "  import: Adds targets from Ansible inventory, MACs from ethers and SSH settings from ssh_config to config")
+	fmt.Fprintln(flag.CommandLine.Output(), "")
+	fmt.Fprintln(flag.CommandLine.Output(), " Flags:")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+// applyServerConfig sets flags from server section of config file, unless
+// they were given on command line.
+func applyServerConfig(server config.ServerConfiguration) {
+	for name, value := range server.Flags() {
+		if flagFromCommandLine(name) {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			log.Fatalf("Invalid value of %s in server section of config file '%s': %v", name, config.PrintPath(), err)
+		}
+	}
+}
+
+// serverConfig returns local configuration used by the HTTP server. Run
+// targets of the configuration are the targets registered to the server.
+// Server runs without configuration only when there is no config file.
+func serverConfig(localConfig *config.LocalConfiguration, err error) *config.LocalConfiguration {
+	if errors.Is(err, config.ErrNotFound) {
+		log.Warningf("Server runs without local configuration: %v", err)
+		return &config.LocalConfiguration{}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return localConfig
+}
+
+func main() {
+	flag.Parse()
+	recordCommandLineFlags()
+	config.SetPath(*configFlag)
+	localConfig, configErr := config.Load()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		failWithUsage()
+	}
+
+	// server section configures only the server, other commands must work
+	// even when it is invalid
+	if args[0] == "http" && configErr == nil {
+		applyServerConfig(localConfig.Server)
+	}
+
+	sshctl.SetKnownHostsFile(*knownHostsFlag)
+	sshctl.DefaultPool.SetIdleTimeout(*sshIdleTimeoutFlag)
+
+	// server must not block on terminal, it takes passphrase from environment
+	if args[0] != "http" {
+		secret.SetPrompt(promptFromTerminal)
+	}
+
+	switch args[0] {
+	case "http":
+		localConfig := serverConfig(localConfig, configErr)
+
+		probeCapabilities := probing.DetectCapabilities()
+		log.Infof("Probe mode: %s, %s", probeCapabilities.Mode, probeCapabilities.Reason)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		controller.SetServerContext(ctx)
+
+		api := server.InitApiCore()
+		api.SetHttp(*httpAddrFlag)
+		api.SetHttps(*httpsAddrFlag, *httpsCertFlag, *httpsKeyFlag)
+		api.SetTargets(localConfig.RunTargets)
+		api.SetVersion(version)
+		api.SetJobRetention(*jobRetentionFlag)
+
+		api.SetAuthToken(resolveSecretFlag("auth_token", *httpAuthTokenFlag, "HOMECONTROLLER_AUTH_TOKEN"))
+		if *dashboardFlag {
+			api.EnableDashboard()
+		}
+		if *adhocSshFlag {
+			api.AllowAdhocSsh()
+		}
+
+		reloader := &configReloader{api: api, current: localConfig}
+		reloader.startWebhooks(localConfig.Webhooks)
+		defer reloader.stopWebhooks()
+
+		monitor := controller.NewStatusMonitor(localConfig.RunTargets)
+		reloader.monitor = monitor
+		if *mqttBrokerFlag != "" {
+			bridge := server.NewMqttBridge(server.MqttOptions{
+				Broker:          *mqttBrokerFlag,
+				ClientId:        *mqttClientIdFlag,
+				User:            *mqttUserFlag,
+				Password:        resolveSecretFlag("mqtt_password", *mqttPasswordFlag, "HOMECONTROLLER_MQTT_PASSWORD"),
+				TopicPrefix:     *mqttTopicPrefixFlag,
+				DiscoveryPrefix: *mqttDiscoveryPrefixFlag,
+			}, monitor.Targets(), monitor)
+			if err := bridge.Start(); err != nil {
+				log.Fatalf("Could not start MQTT bridge: %v", err)
+			}
+			defer bridge.Stop()
+			reloader.bridge = bridge
+		}
+		monitor.Start()
+		defer monitor.Stop()
+
+		go reloader.Run(ctx)
+
+		if err := api.Serve(ctx); err != nil {
+			log.Fatal(err)
+		}
+		break
+	case "run":
+		if len(args) < 2 {
+			log.Fatal("command run must have an argument: homecontroller --target=[target] run [COMMAND]")
+			return
+		}
+
+		handleRunCommand(*cmdTargetFlag, args[1], args[2:])
+		break
+
+	case "remote-run":
+		if len(args) < 2 {
+			log.Fatal("command remote-run must have an argument: homecontroller --remote=[remote] --target=[target] remote-run [COMMAND]")
+			return
+		}
+		handleRemoteCommand(*cmdRemoteFlag, *cmdTargetFlag, args[1], args[2:])
+		break
+	case "ssh":
+		if len(args) < 2 || args[1] != "trust" {
+			log.Fatal("command ssh must have an argument: homecontroller [--remote=[remote]] --target=[target] ssh trust")
+			return
+		}
+
+		handleSshTrustCommand(*cmdRemoteFlag, *cmdTargetFlag)
+		break
+	case "doctor":
+		handleDoctorCommand(*cmdRemoteFlag, *cmdTargetFlag)
+		break
+	case "tui":
+		handleTuiCommand()
+		break
+	case "secret":
+		if len(args) < 3 || args[1] != "set" {
+			log.Fatal("command secret must have an argument: homecontroller secret set [REFERENCE]")
+			return
+		}
+
+		handleSecretSetCommand(args[2])
+		break
+	case "import":
+		if len(args) < 2 {
+			log.Fatal("command import must have an argument: homecontroller import ansible:[INVENTORY] [ethers[:PATH]] [ssh_config[:PATH]]")
+			return
+		}
+
+		handleImportCommand(args[1:])
+		break
+	case "config":
+		if len(args) < 2 || args[1] != "schema" {
+			log.Fatal("command config must have an argument: homecontroller config schema")
+			return
+		}
+
+		handleConfigSchemaCommand()
+		break
+	default:
+		failWithUsage()
+		break
+	}
+
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"homecontroller/controller"
+)
+
+func printStatusResponse(targetConfigId string, isOnline bool) {
+	if isOnline {
+		fmt.Printf("Target '%s' is ONLINE\n", targetConfigId)
+	} else {
+		fmt.Printf("Target '%s' is OFFLINE\n", targetConfigId)
+	}
+}
+
+func printExecResult(result controller.ExecResult) {
+	fmt.Fprint(os.Stdout, result.Stdout)
+	fmt.Fprint(os.Stderr, result.Stderr)
+}
+
+func printHookResults(hooks []controller.HookResult) {
+	for _, hook := range hooks {
+		location := "ssh"
+		if hook.Local {
+			location = "local"
+		}
+
+		if hook.Failed() {
+			fmt.Printf("Hook %s (%s) failed: %s\n", hook.Hook, location, hook.Describe())
+		} else {
+			fmt.Printf("Hook %s (%s) finished\n", hook.Hook, location)
+		}
+		printExecResult(hook.ExecResult)
+	}
+}
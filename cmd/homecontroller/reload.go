@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"homecontroller/config"
+	"homecontroller/controller"
+	"homecontroller/server"
+	"homecontroller/sshctl"
+)
+
+// configPollInterval is how often config files are checked for changes.
+const configPollInterval = 2 * time.Second
+
+// configReloader applies changed config file to running server, on change of
+// its files or on SIGHUP. New configuration is loaded and validated
+// completely before anything is replaced, invalid configuration is rejected
+// and the server keeps running on the previous one.
+type configReloader struct {
+	api        server.HttpCore
+	monitor    *controller.StatusMonitor
+	bridge     *server.MqttBridge
+	dispatcher *server.WebhookDispatcher
+	current    *config.LocalConfiguration
+}
+
+func (r *configReloader) Run(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	snapshot := config.TakeSnapshot(r.sources())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			log.Info("Reloading config on SIGHUP")
+		case <-ticker.C:
+			if config.TakeSnapshot(r.sources()).Equal(snapshot) {
+				continue
+			}
+			log.Info("Config file changed, reloading")
+		}
+
+		r.reload()
+		// rejected config is not loaded again until it changes
+		snapshot = config.TakeSnapshot(r.sources())
+	}
+}
+
+// sources returns files to watch, search paths when server runs without
+// config file, so that created config file is picked up.
+func (r *configReloader) sources() []string {
+	if len(r.current.Sources) == 0 {
+		return config.SearchPaths()
+	}
+
+	return r.current.Sources
+}
+
+func (r *configReloader) reload() {
+	next, err := config.Load()
+	if err != nil {
+		log.Errorf("Rejected config, server keeps running on previous one: %v", err)
+		return
+	}
+
+	changedFlags, err := parseServerFlags(changedServerFlags(r.current.Server, next.Server))
+	if err != nil {
+		log.Errorf("Rejected config, server keeps running on previous one: %v", err)
+		return
+	}
+
+	var authToken string
+	if value, ok := changedFlags["auth_token"]; ok {
+		authToken, err = secretFlagValue("auth_token", value.(string), "HOMECONTROLLER_AUTH_TOKEN")
+		if err != nil {
+			log.Errorf("Rejected config, server keeps running on previous one: %v", err)
+			return
+		}
+	}
+
+	added, removed, changed := diffTargets(r.current.RunTargets, next.RunTargets)
+	r.api.SetTargets(next.RunTargets)
+	r.monitor.SetTargets(next.RunTargets)
+	if r.bridge != nil {
+		r.bridge.SetTargets(next.RunTargets)
+	}
+
+	if !reflect.DeepEqual(r.current.Webhooks, next.Webhooks) {
+		r.stopWebhooks()
+		r.startWebhooks(next.Webhooks)
+		log.Infof("Webhooks reloaded, %d endpoints", len(next.Webhooks.Endpoints))
+	}
+
+	for name, value := range changedFlags {
+		r.applyFlag(name, value, authToken)
+	}
+
+	r.current = next
+
+	if len(added)+len(removed)+len(changed) == 0 {
+		log.Info("Config reloaded, targets are unchanged")
+		return
+	}
+	log.Infof("Config reloaded, added targets %v, removed targets %v, changed targets %v", added, removed, changed)
+}
+
+// applyFlag applies changed server flag, flags of listeners and MQTT take
+// effect only after restart. Flags themselves keep values read on startup.
+func (r *configReloader) applyFlag(name string, value interface{}, authToken string) {
+	switch name {
+	case "auth_token":
+		r.api.SetAuthToken(authToken)
+	case "job_retention":
+		r.api.SetJobRetention(value.(time.Duration))
+	case "ssh_idle_timeout":
+		sshctl.DefaultPool.SetIdleTimeout(value.(time.Duration))
+	case "known_hosts":
+		sshctl.SetKnownHostsFile(value.(string))
+	default:
+		log.Warningf("Change of %s takes effect after restart", name)
+		return
+	}
+
+	log.Infof("Applied new value of %s", name)
+}
+
+func (r *configReloader) startWebhooks(webhooks config.WebhooksConfiguration) {
+	if len(webhooks.Endpoints) == 0 {
+		r.dispatcher = nil
+		return
+	}
+
+	r.dispatcher = server.NewWebhookDispatcher(webhooks)
+	r.dispatcher.Start()
+}
+
+func (r *configReloader) stopWebhooks() {
+	if r.dispatcher != nil {
+		r.dispatcher.Stop()
+	}
+}
+
+// changedServerFlags returns flags whose value in server section changed,
+// flags removed from the section return to their defaults. Flags given on
+// command line are not affected by config file.
+func changedServerFlags(previous config.ServerConfiguration, next config.ServerConfiguration) map[string]string {
+	previousFlags := previous.Flags()
+	nextFlags := next.Flags()
+
+	changed := make(map[string]string)
+	flag.VisitAll(func(f *flag.Flag) {
+		if flagFromCommandLine(f.Name) {
+			return
+		}
+
+		previousValue, ok := previousFlags[f.Name]
+		if !ok {
+			previousValue = f.DefValue
+		}
+		value, ok := nextFlags[f.Name]
+		if !ok {
+			value = f.DefValue
+		}
+
+		if previousValue != value {
+			changed[f.Name] = value
+		}
+	})
+
+	return changed
+}
+
+// parseServerFlags parses values of flags into values of their types, without
+// setting the flags, so that whole config is validated before it is applied.
+func parseServerFlags(values map[string]string) (map[string]interface{}, error) {
+	parsed := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		f := flag.Lookup(name)
+		if f == nil {
+			return nil, fmt.Errorf("unknown flag %s in server section", name)
+		}
+
+		// fresh value of the same type as flag
+		scratch := reflect.New(reflect.TypeOf(f.Value).Elem()).Interface().(flag.Value)
+		if err := scratch.Set(value); err != nil {
+			return nil, fmt.Errorf("invalid value of %s in server section: %v", name, err)
+		}
+		parsed[name] = scratch.(flag.Getter).Get()
+	}
+
+	return parsed, nil
+}
+
+func diffTargets(previous []config.TargetConfiguration, next []config.TargetConfiguration) (added []string, removed []string, changed []string) {
+	for i := range next {
+		old := config.TargetById(previous, next[i].Id)
+		if old == nil {
+			added = append(added, next[i].Id)
+		} else if !reflect.DeepEqual(*old, next[i]) {
+			changed = append(changed, next[i].Id)
+		}
+	}
+
+	for i := range previous {
+		if config.TargetById(next, previous[i].Id) == nil {
+			removed = append(removed, previous[i].Id)
+		}
+	}
+
+	return added, removed, changed
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"homecontroller/client"
+	"homecontroller/config"
+	"homecontroller/controller"
+	"homecontroller/secret"
+)
+
+func handleRemoteCommand(remoteId, targetId string, command string, args []string) {
+	remoteConfig, targetConfig := loadRemoteTarget(remoteId, targetId)
+
+	if *cmdFollowFlag {
+		followRemoteAction(remoteConfig, targetConfig, command, args)
+		return
+	}
+
+	api, err := newRemoteClient(remoteConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+
+	switch command {
+	case "wake":
+		err := api.Wake(ctx, remoteWakeRequest(targetConfig))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("Wake request sent to %s.\n", targetConfig.Host)
+	case "halt":
+		request, err := remoteHaltRequest(targetConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = api.Halt(ctx, request)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("Halt request sent to %s.\n", targetConfig.Host)
+	case "reboot":
+		// remote server has no raw reboot endpoint, target must be registered there
+		_, err := api.RebootTarget(ctx, targetConfig.Id)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("Reboot request sent to %s.\n", targetConfig.Host)
+	case "status":
+		status, err := api.Status(ctx, targetConfig.Host)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		printStatusResponse(targetConfig.Id, status.IsOnline)
+	case "exec":
+		if len(args) < 1 {
+			log.Fatal("command exec must have an argument: homecontroller --remote=[remote] --target=[target] remote-run exec [NAME]")
+			return
+		}
+
+		// command is configured on remote server for target with the same id
+		result, err := api.Exec(ctx, targetConfig.Id, args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		printExecResult(execDataFromClient(result))
+		os.Exit(result.ExitCode)
+	//case "status-stream":
+	default:
+		log.Fatalf("Cannot handle command '%s': unknown command", command)
+	}
+}
+
+func loadRemoteTarget(remoteId, targetId string) (*config.RemoteConfiguration, *config.TargetConfiguration) {
+	if remoteId == "" {
+		log.Fatal("missing flag --remote")
+	}
+
+	if targetId == "" {
+		log.Fatal("missing flag --target")
+	}
+
+	localConfig, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	remoteConfig := localConfig.RemoteById(remoteId)
+	if remoteConfig == nil {
+		log.Fatalf("Configuration '%s' not found in config file", remoteId)
+	}
+
+	targetConfig := config.TargetById(remoteConfig.Targets, targetId)
+	if targetConfig == nil {
+		log.Fatalf("Target '%s' not found in for configuration %s", targetId, remoteConfig.Id)
+	}
+
+	return remoteConfig, targetConfig
+}
+
+// newRemoteClient returns client of remote server, auth token reference is
+// resolved now.
+func newRemoteClient(remoteConfig *config.RemoteConfiguration) (*client.Client, error) {
+	token, err := secret.Ref(remoteConfig.AuthToken).Resolve()
+	if err != nil {
+		return nil, fmt.Errorf("couldnt resolve auth_token of remote %s, %v", remoteConfig.Id, err)
+	}
+
+	return client.New(remoteConfig.Host, token), nil
+}
+
+func remoteWakeRequest(targetConfig *config.TargetConfiguration) client.WakeRequest {
+	request := client.WakeRequest{
+		Mac:           targetConfig.GetMac(),
+		Host:          targetConfig.Host,
+		VerifyTimeout: int(targetConfig.WakeTimeout.Seconds()),
+	}
+
+	for _, address := range targetConfig.GetBroadcastAddress() {
+		request.BroadcastAddress = append(request.BroadcastAddress, &client.BroadcastAddress{
+			Ip:   string(address.Ip),
+			Port: address.Port,
+		})
+	}
+
+	return request
+}
+
+// remoteHaltRequest returns halt request of target. Remote server gets values
+// of secrets, as it cannot resolve references of this configuration.
+func remoteHaltRequest(targetConfig *config.TargetConfiguration) (client.HaltRequest, error) {
+	password, err := secret.Ref(targetConfig.Ssh.Password).Resolve()
+	if err != nil {
+		return client.HaltRequest{}, err
+	}
+
+	privateKey, err := remotePrivateKey(targetConfig.Ssh.PrivateKey)
+	if err != nil {
+		return client.HaltRequest{}, err
+	}
+
+	request := client.HaltRequest{
+		User:        targetConfig.Ssh.User,
+		Host:        targetConfig.Host,
+		Port:        targetConfig.Ssh.Port,
+		Password:    password,
+		PrivateKey:  privateKey,
+		Certificate: targetConfig.Ssh.Certificate,
+		HostKey:     targetConfig.Ssh.HostKey,
+	}
+
+	for _, jump := range targetConfig.Ssh.ProxyJump {
+		password, err := secret.Ref(jump.Password).Resolve()
+		if err != nil {
+			return client.HaltRequest{}, err
+		}
+
+		privateKey, err := remotePrivateKey(jump.PrivateKey)
+		if err != nil {
+			return client.HaltRequest{}, err
+		}
+
+		request.ProxyJump = append(request.ProxyJump, client.JumpHost{
+			Host:        jump.Host,
+			User:        jump.User,
+			Port:        jump.Port,
+			Password:    password,
+			PrivateKey:  privateKey,
+			Certificate: jump.Certificate,
+			HostKey:     jump.HostKey,
+		})
+	}
+
+	return request, nil
+}
+
+func remotePrivateKey(key config.SshPrivateKeyOptions) (*client.PrivateKey, error) {
+	if key.Path == "" {
+		return nil, nil
+	}
+
+	passphrase, err := secret.Ref(key.Passphrase).Resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	return &client.PrivateKey{Path: key.Path, Passphrase: passphrase}, nil
+}
+
+func execDataFromClient(result client.ExecResult) controller.ExecResult {
+	return controller.ExecResult{Stdout: result.Stdout, Stderr: result.Stderr, ExitCode: result.ExitCode}
+}
+
+func hookResultsFromClient(results []client.HookResult) []controller.HookResult {
+	var hooks []controller.HookResult
+	for _, result := range results {
+		hooks = append(hooks, controller.HookResult{
+			Hook:       result.Hook,
+			Local:      result.Local,
+			ExecResult: execDataFromClient(result.ExecResult),
+			Error:      result.Error,
+		})
+	}
+
+	return hooks
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"homecontroller/client"
+	"homecontroller/config"
+
+	"golang.org/x/term"
+)
+
+const jobPollInterval = 500 * time.Millisecond
+
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+// followRemoteAction runs action of target registered on remote server as
+// job and displays its progress until it finishes. Interrupt cancels the
+// job.
+func followRemoteAction(remoteConfig *config.RemoteConfiguration, targetConfig *config.TargetConfiguration, command string, args []string) {
+	api, err := newRemoteClient(remoteConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+
+	var job client.Job
+	switch command {
+	case "wake":
+		job, err = api.StartWakeTarget(ctx, targetConfig.Id)
+	case "halt":
+		job, err = api.StartHaltTarget(ctx, targetConfig.Id)
+	case "reboot":
+		job, err = api.StartRebootTarget(ctx, targetConfig.Id)
+	case "exec":
+		if len(args) < 1 {
+			log.Fatal("command exec must have an argument: homecontroller --remote=[remote] --target=[target] --follow remote-run exec [NAME]")
+			return
+		}
+		job, err = api.StartExec(ctx, targetConfig.Id, args[0])
+	default:
+		log.Fatalf("Command '%s' cannot be followed", command)
+		return
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	defer signal.Stop(interrupted)
+
+	display := newProgressDisplay()
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for job.Finished == nil {
+		display.Show(job)
+
+		select {
+		case <-interrupted:
+			display.Println("Cancelling job %s", job.Id)
+			job, err = api.CancelJob(ctx, job.Id)
+		case <-ticker.C:
+			job, err = api.Job(ctx, job.Id)
+		}
+
+		if err != nil {
+			display.Println("")
+			log.Fatal(err)
+		}
+	}
+
+	display.Done(job)
+	printJobResult(job)
+}
+
+func printJobResult(job client.Job) {
+	exitCode := 0
+	if len(job.Result) > 0 {
+		if job.Action == "exec" {
+			if result, err := job.ExecResult(); err == nil {
+				printExecResult(execDataFromClient(result))
+				exitCode = result.ExitCode
+			}
+		} else {
+			if result, err := job.ActionResult(); err == nil {
+				printHookResults(hookResultsFromClient(result.Hooks))
+			}
+		}
+	}
+
+	switch job.State {
+	case client.JobStateFailed:
+		message := "unknown error"
+		if job.Error != nil {
+			message = job.Error.Message
+		}
+		log.Fatalf("Job %s failed: %s", job.Id, message)
+	case client.JobStateCancelled:
+		fmt.Printf("Job %s was cancelled\n", job.Id)
+		os.Exit(1)
+	}
+
+	os.Exit(exitCode)
+}
+
+// progressDisplay keeps single updating status line on terminal, otherwise
+// it prints every change of progress on new line.
+type progressDisplay struct {
+	isTerminal bool
+	start      time.Time
+	frame      int
+	last       string
+}
+
+func newProgressDisplay() *progressDisplay {
+	return &progressDisplay{
+		isTerminal: term.IsTerminal(int(os.Stdout.Fd())),
+		start:      time.Now(),
+	}
+}
+
+func (d *progressDisplay) Show(job client.Job) {
+	status := fmt.Sprintf("%s %s: %s", job.Action, job.Target, job.State)
+	if job.Progress != "" {
+		status += ", " + job.Progress
+	}
+
+	if d.isTerminal {
+		d.frame = (d.frame + 1) % len(spinnerFrames)
+		fmt.Printf("\r\033[K%s %s (%s)", spinnerFrames[d.frame], status, time.Since(d.start).Round(time.Second))
+		return
+	}
+
+	if status != d.last {
+		fmt.Println(status)
+		d.last = status
+	}
+}
+
+func (d *progressDisplay) Println(format string, args ...interface{}) {
+	if d.isTerminal {
+		fmt.Print("\r\033[K")
+	}
+	fmt.Printf(format+"\n", args...)
+}
+
+func (d *progressDisplay) Done(job client.Job) {
+	d.Println("%s %s: %s (%s)", job.Action, job.Target, job.State, time.Since(d.start).Round(time.Second))
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"homecontroller/secret"
+
+	"golang.org/x/term"
+)
+
+// handleSecretSetCommand asks for value of secret and stores it in backend
+// of reference, so that config file only contains the reference.
+func handleSecretSetCommand(reference string) {
+	ref := secret.Ref(reference)
+	if !ref.IsReference() {
+		log.Fatal("secret must be reference: env:VAR, file:PATH or keyring:SERVICE/ACCOUNT")
+		return
+	}
+
+	value, err := promptFromTerminal(fmt.Sprintf("Value of %s: ", ref))
+	if err != nil {
+		log.Fatalf("Could not read value: %v", err)
+		return
+	}
+	if value == "" {
+		log.Fatal("value must not be empty")
+		return
+	}
+
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		repeated, err := promptFromTerminal("Repeat value: ")
+		if err != nil {
+			log.Fatalf("Could not read value: %v", err)
+			return
+		}
+		if repeated != value {
+			log.Fatal("values do not match")
+			return
+		}
+	}
+
+	if err := secret.Set(ref, value); err != nil {
+		log.Fatalf("Could not store secret %s: %v", ref, err)
+		return
+	}
+
+	fmt.Printf("Secret %s was stored, use it in config file as '%s'\n", ref, ref)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"homecontroller/client"
+	"homecontroller/config"
+	"homecontroller/sshctl"
+)
+
+func handleSshTrustCommand(remoteId, targetId string) {
+	if remoteId != "" {
+		handleRemoteSshTrust(remoteId, targetId)
+		return
+	}
+
+	if targetId == "" {
+		log.Fatal("missing flag --target")
+		return
+	}
+
+	localConfig, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+
+	targetConfig := config.TargetById(localConfig.RunTargets, targetId)
+	if targetConfig == nil {
+		log.Fatalf("Run target '%s' not found", targetId)
+		return
+	}
+
+	// jump hosts must be trusted before hosts behind them can be reached
+	dest := targetConfig.SshDestination().Resolve()
+	for i, jump := range dest.Jumps {
+		if jump.HostKey != "" {
+			continue
+		}
+
+		jump.Jumps = dest.Jumps[:i]
+		trustDestinationHostKey(jump)
+	}
+
+	if dest.HostKey != "" {
+		fmt.Printf("Host key of %s is pinned in configuration\n", dest.Addr())
+		return
+	}
+
+	trustDestinationHostKey(dest)
+}
+
+func trustDestinationHostKey(dest sshctl.Destination) {
+	key, data, err := sshctl.InspectHostKey(context.Background(), dest)
+	if err != nil {
+		log.Fatalf("Could not obtain host key of %s: %v", dest.Addr(), err)
+		return
+	}
+
+	if !confirmHostKey(data) {
+		return
+	}
+
+	if err := sshctl.TrustHostKey(key, data); err != nil {
+		log.Fatalf("Could not trust host key: %v", err)
+		return
+	}
+
+	fmt.Printf("Host key of %s recorded\n", data.Host)
+}
+
+// handleRemoteSshTrust records host key in known_hosts of remote server,
+// which then verifies that it receives the confirmed key as well.
+func handleRemoteSshTrust(remoteId, targetId string) {
+	remoteConfig, targetConfig := loadRemoteTarget(remoteId, targetId)
+
+	api, err := newRemoteClient(remoteConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+
+	hostKey, err := api.KnownHost(ctx, targetConfig.Host, targetConfig.Ssh.Port)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	data := sshctl.HostKeyInfo(hostKey)
+	if !confirmHostKey(data) {
+		return
+	}
+
+	_, err = api.TrustKnownHost(ctx, client.TrustHostKeyRequest{
+		Host:        targetConfig.Host,
+		Port:        targetConfig.Ssh.Port,
+		Fingerprint: data.Fingerprint,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Host key of %s recorded on %s\n", data.Host, remoteConfig.Id)
+}
+
+// confirmHostKey shows host key to user and asks whether it should be
+// trusted. Already known keys need no confirmation.
+func confirmHostKey(data sshctl.HostKeyInfo) bool {
+	fmt.Printf("Host %s presented %s key %s\n", data.Host, data.Type, data.Fingerprint)
+
+	if data.Known {
+		fmt.Println("Host key is already known")
+		return false
+	}
+
+	if data.Changed {
+		fmt.Println("WARNING: host key differs from the known one, host may have been reinstalled or connection is intercepted")
+		return confirm("Replace known host key?")
+	}
+
+	return confirm("Trust this host key?")
+}
@@ -0,0 +1,542 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"homecontroller/client"
+	"homecontroller/config"
+	"homecontroller/controller"
+	"homecontroller/probing"
+	"homecontroller/secret"
+
+	"github.com/op/go-logging"
+	"golang.org/x/term"
+)
+
+const (
+	tuiRefreshInterval   = time.Second
+	tuiReconnectInterval = 5 * time.Second
+	tuiLogSize           = 200
+)
+
+const (
+	tuiStatusUnknown = "unknown"
+	tuiStatusOnline  = "online"
+	tuiStatusOffline = "offline"
+)
+
+// tuiRow is run target, or target of remote when remote is set.
+type tuiRow struct {
+	target *config.TargetConfiguration
+	remote *config.RemoteConfiguration
+	status string
+	busy   string
+}
+
+func (r *tuiRow) via() string {
+	if r.remote == nil {
+		return "direct"
+	}
+
+	return r.remote.Id
+}
+
+type tuiPrompt struct {
+	question string
+	reply    chan tuiPromptReply
+}
+
+type tuiPromptReply struct {
+	answer string
+	err    error
+}
+
+// tui shows targets with live status and runs actions on them. State is
+// owned by the loop in run, other goroutines post changes to it.
+type tui struct {
+	fd        int
+	termState *term.State
+	input     *keyReader
+
+	rows     []*tuiRow
+	selected int
+	confirm  string
+	logs     []string
+
+	updates chan func()
+	prompts chan tuiPrompt
+	done    chan struct{}
+}
+
+func handleTuiCommand() {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		log.Fatal("command tui must run in terminal")
+		return
+	}
+
+	localConfig, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+
+	t := newTui(fd, localConfig)
+	if len(t.rows) == 0 {
+		log.Fatal("no run targets or remote targets in config file")
+		return
+	}
+
+	if err := t.run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func newTui(fd int, localConfig *config.LocalConfiguration) *tui {
+	t := &tui{
+		fd:      fd,
+		updates: make(chan func(), 64),
+		prompts: make(chan tuiPrompt),
+		done:    make(chan struct{}),
+	}
+
+	for i := range localConfig.RunTargets {
+		t.rows = append(t.rows, &tuiRow{target: &localConfig.RunTargets[i], status: tuiStatusUnknown})
+	}
+
+	for i := range localConfig.Remote {
+		remote := &localConfig.Remote[i]
+		for j := range remote.Targets {
+			t.rows = append(t.rows, &tuiRow{target: &remote.Targets[j], remote: remote, status: tuiStatusUnknown})
+		}
+	}
+
+	return t
+}
+
+func (t *tui) run() error {
+	if err := t.enterScreen(); err != nil {
+		return err
+	}
+	defer t.leaveScreen()
+
+	// log output would break the screen, it is shown in log pane instead
+	backend := logging.AddModuleLevel(logging.NewLogBackend(tuiLogWriter{t}, "", 0))
+	backend.SetLevel(logging.INFO, "")
+	logging.SetBackend(backend)
+	defer logging.Reset()
+
+	t.input = newKeyReader(os.Stdin)
+	defer t.input.Close()
+
+	secret.SetPrompt(t.prompt)
+	defer secret.SetPrompt(promptFromTerminal)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer close(t.done)
+
+	t.startObservers(ctx)
+
+	ticker := time.NewTicker(tuiRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		t.render()
+
+		select {
+		case data := <-t.input.Keys():
+			if !t.handleKeys(data) {
+				return nil
+			}
+		case update := <-t.updates:
+			update()
+		case prompt := <-t.prompts:
+			t.handlePrompt(prompt)
+		case <-ticker.C:
+			// redraw, terminal may have been resized
+		}
+	}
+}
+
+func (t *tui) enterScreen() error {
+	state, err := term.MakeRaw(t.fd)
+	if err != nil {
+		return fmt.Errorf("couldnt switch terminal to raw mode, %v", err)
+	}
+
+	t.termState = state
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	return nil
+}
+
+func (t *tui) leaveScreen() {
+	fmt.Print("\x1b[?25h\x1b[?1049l")
+	if t.termState != nil {
+		term.Restore(t.fd, t.termState)
+		t.termState = nil
+	}
+}
+
+// post runs update in the loop of tui.
+func (t *tui) post(update func()) {
+	select {
+	case t.updates <- update:
+	case <-t.done:
+	}
+}
+
+func (t *tui) logf(format string, args ...interface{}) {
+	line := time.Now().Format(time.TimeOnly) + " " + fmt.Sprintf(format, args...)
+	t.logs = append(t.logs, line)
+	if len(t.logs) > tuiLogSize {
+		t.logs = t.logs[len(t.logs)-tuiLogSize:]
+	}
+}
+
+type tuiLogWriter struct {
+	t *tui
+}
+
+func (w tuiLogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimRight(string(p), "\n")
+	w.t.post(func() {
+		w.t.logf("%s", msg)
+	})
+	return len(p), nil
+}
+
+func (t *tui) setStatus(row *tuiRow, status string) {
+	if row.status != status && row.status != tuiStatusUnknown {
+		t.logf("%s is %s", row.target.Id, status)
+	}
+	row.status = status
+}
+
+func (t *tui) startObservers(ctx context.Context) {
+	remoteRows := make(map[*config.RemoteConfiguration][]*tuiRow)
+	for _, row := range t.rows {
+		if row.remote == nil {
+			go t.observeLocal(ctx, row)
+		} else {
+			remoteRows[row.remote] = append(remoteRows[row.remote], row)
+		}
+	}
+
+	for remote, rows := range remoteRows {
+		go t.observeRemote(ctx, remote, rows)
+	}
+}
+
+func (t *tui) observeLocal(ctx context.Context, row *tuiRow) {
+	updates := make(chan probing.Status)
+	go func() {
+		err := probing.Observe(ctx, row.target.Host, row.target.ProbeConfig(), updates)
+		if err != nil {
+			t.post(func() {
+				t.logf("Could not observe status of %s: %v", row.target.Id, err)
+			})
+		}
+	}()
+
+	var last *probing.Status
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case status := <-updates:
+			if last != nil && *last == status {
+				continue
+			}
+			last = &status
+
+			t.post(func() {
+				t.setStatus(row, onlineStatus(status.IsOnline))
+			})
+		}
+	}
+}
+
+// observeRemote follows status of remote targets via status stream of the
+// remote server, reconnecting when connection is lost.
+func (t *tui) observeRemote(ctx context.Context, remote *config.RemoteConfiguration, rows []*tuiRow) {
+	api, err := newRemoteClient(remote)
+	if err != nil {
+		t.post(func() {
+			t.logf("Could not connect to remote %s: %v", remote.Id, err)
+		})
+		return
+	}
+
+	var hosts []string
+	for _, row := range rows {
+		hosts = append(hosts, row.target.Host)
+	}
+
+	for {
+		updates, err := api.StatusStream(ctx, hosts)
+		if err != nil {
+			t.post(func() {
+				t.logf("Could not connect to remote %s: %v", remote.Id, err)
+			})
+		} else {
+			for update := range updates {
+				t.post(func() {
+					for _, row := range rows {
+						if row.target.Host == update.Target {
+							t.setStatus(row, onlineStatus(update.Status.IsOnline))
+						}
+					}
+				})
+			}
+
+			if ctx.Err() == nil {
+				t.post(func() {
+					t.logf("Connection to remote %s was lost", remote.Id)
+				})
+			}
+		}
+
+		t.post(func() {
+			for _, row := range rows {
+				row.status = tuiStatusUnknown
+			}
+		})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(tuiReconnectInterval):
+		}
+	}
+}
+
+func onlineStatus(isOnline bool) string {
+	if isOnline {
+		return tuiStatusOnline
+	}
+
+	return tuiStatusOffline
+}
+
+func (t *tui) handleKeys(data []byte) bool {
+	for len(data) > 0 {
+		var key string
+		key, data = nextKey(data)
+
+		switch key {
+		case "q", "\x03":
+			return false
+		case "up", "k":
+			t.moveSelection(-1)
+		case "down", "j":
+			t.moveSelection(1)
+		case "w":
+			t.confirm = ""
+			t.startAction(t.rows[t.selected], "wake")
+		case "h":
+			t.confirm = "halt"
+		case "r":
+			t.confirm = "reboot"
+		case "y":
+			if t.confirm != "" {
+				action := t.confirm
+				t.confirm = ""
+				t.startAction(t.rows[t.selected], action)
+			}
+		default:
+			t.confirm = ""
+		}
+	}
+
+	return true
+}
+
+// nextKey splits first key from input, arrow keys are named.
+func nextKey(data []byte) (string, []byte) {
+	if len(data) >= 3 && data[0] == '\x1b' && (data[1] == '[' || data[1] == 'O') {
+		switch data[2] {
+		case 'A':
+			return "up", data[3:]
+		case 'B':
+			return "down", data[3:]
+		default:
+			return "", data[3:]
+		}
+	}
+
+	return string(data[:1]), data[1:]
+}
+
+func (t *tui) moveSelection(delta int) {
+	t.confirm = ""
+	t.selected += delta
+	if t.selected < 0 {
+		t.selected = 0
+	}
+	if t.selected >= len(t.rows) {
+		t.selected = len(t.rows) - 1
+	}
+}
+
+func (t *tui) startAction(row *tuiRow, action string) {
+	if row.busy != "" {
+		t.logf("%s: %s is still running", row.target.Id, row.busy)
+		return
+	}
+
+	row.busy = action
+	t.logf("%s: %s started via %s", row.target.Id, action, row.via())
+
+	go func() {
+		err := t.runAction(row, action)
+		t.post(func() {
+			row.busy = ""
+			if err != nil {
+				t.logf("%s: %s failed: %v", row.target.Id, action, err)
+			} else {
+				t.logf("%s: %s finished", row.target.Id, action)
+			}
+		})
+	}()
+}
+
+// runAction runs action directly or via remote server. Hooks of direct
+// actions report their results to the log.
+func (t *tui) runAction(row *tuiRow, action string) error {
+	ctx := context.Background()
+
+	var err error
+	if row.remote == nil {
+		switch action {
+		case "wake":
+			_, err = controller.Wake(ctx, row.target, t.prompt)
+		case "halt":
+			_, err = controller.Halt(ctx, row.target, t.prompt)
+		case "reboot":
+			_, err = controller.Reboot(ctx, row.target, t.prompt)
+		}
+		return err
+	}
+
+	api, err := newRemoteClient(row.remote)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "wake":
+		err = api.Wake(ctx, remoteWakeRequest(row.target))
+	case "halt":
+		var request client.HaltRequest
+		request, err = remoteHaltRequest(row.target)
+		if err == nil {
+			err = api.Halt(ctx, request)
+		}
+	case "reboot":
+		// remote server has no raw reboot endpoint, target must be registered there
+		_, err = api.RebootTarget(ctx, row.target.Id)
+	}
+	return err
+}
+
+// prompt asks for secret via readPassword, screen of tui is left meanwhile.
+func (t *tui) prompt(question string) (string, error) {
+	reply := make(chan tuiPromptReply, 1)
+	select {
+	case t.prompts <- tuiPrompt{question, reply}:
+	case <-t.done:
+		return "", errors.New("tui was closed")
+	}
+
+	result := <-reply
+	return result.answer, result.err
+}
+
+func (t *tui) handlePrompt(prompt tuiPrompt) {
+	if err := t.input.Pause(); err != nil {
+		prompt.reply <- tuiPromptReply{err: err}
+		return
+	}
+	defer t.input.Resume()
+
+	t.leaveScreen()
+	answer, err := promptFromTerminal(prompt.question)
+	if screenErr := t.enterScreen(); screenErr != nil && err == nil {
+		err = screenErr
+	}
+
+	prompt.reply <- tuiPromptReply{answer, err}
+}
+
+func (t *tui) render() {
+	width, height, err := term.GetSize(t.fd)
+	if err != nil {
+		width, height = 80, 24
+	}
+
+	idWidth, viaWidth, hostWidth := len("TARGET"), len("VIA"), len("HOST")
+	for _, row := range t.rows {
+		idWidth = max(idWidth, len(row.target.Id))
+		viaWidth = max(viaWidth, len(row.via()))
+		hostWidth = max(hostWidth, len(row.target.Host))
+	}
+
+	var lines []string
+	lines = append(lines, "\x1b[1mhomecontroller\x1b[0m", "")
+	lines = append(lines, truncate(fmt.Sprintf("  %-*s  %-*s  %-*s  %-8s  %s", idWidth, "TARGET", viaWidth, "VIA", hostWidth, "HOST", "STATUS", "ACTION"), width))
+
+	for i, row := range t.rows {
+		line := fmt.Sprintf("  %-*s  %-*s  %-*s  %-8s  %s", idWidth, row.target.Id, viaWidth, row.via(), hostWidth, row.target.Host, row.status, row.busy)
+		line = truncate(line, width)
+
+		switch row.status {
+		case tuiStatusOnline:
+			line = strings.Replace(line, tuiStatusOnline, "\x1b[32m"+tuiStatusOnline+"\x1b[39m", 1)
+		case tuiStatusOffline:
+			line = strings.Replace(line, tuiStatusOffline, "\x1b[31m"+tuiStatusOffline+"\x1b[39m", 1)
+		}
+
+		if i == t.selected {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		}
+		lines = append(lines, line)
+	}
+
+	lines = append(lines, "")
+	if t.confirm != "" {
+		lines = append(lines, truncate(fmt.Sprintf("%s %s? press y to confirm, any other key cancels", t.confirm, t.rows[t.selected].target.Id), width))
+	} else {
+		lines = append(lines, truncate("up/down select  w wake  h halt  r reboot  q quit", width))
+	}
+	lines = append(lines, strings.Repeat("-", width))
+
+	// rest of the screen is log pane showing latest lines
+	logLines := height - len(lines)
+	if logLines > 0 {
+		start := max(len(t.logs)-logLines, 0)
+		for _, line := range t.logs[start:] {
+			lines = append(lines, truncate(line, width))
+		}
+	}
+
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+
+	fmt.Print("\x1b[H\x1b[2J" + strings.Join(lines, "\r\n"))
+}
+
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+
+	return string(runes[:width])
+}
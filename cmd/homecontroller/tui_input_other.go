@@ -0,0 +1,57 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// keyReader reads keys from terminal. Blocking read cannot be interrupted
+// here, so it cannot be paused for readPassword.
+type keyReader struct {
+	keys chan []byte
+	done chan struct{}
+}
+
+func newKeyReader(file *os.File) *keyReader {
+	r := &keyReader{
+		keys: make(chan []byte, 16),
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			read, err := file.Read(buf)
+			if err != nil {
+				return
+			}
+
+			data := make([]byte, read)
+			copy(data, buf[:read])
+			select {
+			case r.keys <- data:
+			case <-r.done:
+				return
+			}
+		}
+	}()
+
+	return r
+}
+
+func (r *keyReader) Keys() <-chan []byte {
+	return r.keys
+}
+
+func (r *keyReader) Pause() error {
+	return errors.New("prompt is not supported by tui on this platform, configure credentials in config file")
+}
+
+func (r *keyReader) Resume() {
+}
+
+func (r *keyReader) Close() {
+	close(r.done)
+}
@@ -0,0 +1,101 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const keyPollTimeoutMs = 100
+
+// keyReader reads keys from terminal. Reading polls with timeout, so that it
+// can be paused while readPassword reads from the same terminal.
+type keyReader struct {
+	file   *os.File
+	keys   chan []byte
+	pause  chan chan struct{}
+	resume chan struct{}
+	done   chan struct{}
+}
+
+func newKeyReader(file *os.File) *keyReader {
+	r := &keyReader{
+		file:   file,
+		keys:   make(chan []byte, 16),
+		pause:  make(chan chan struct{}),
+		resume: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+func (r *keyReader) run() {
+	buf := make([]byte, 64)
+	for {
+		select {
+		case <-r.done:
+			return
+		case ack := <-r.pause:
+			close(ack)
+			select {
+			case <-r.resume:
+			case <-r.done:
+				return
+			}
+			continue
+		default:
+		}
+
+		fds := []unix.PollFd{{Fd: int32(r.file.Fd()), Events: unix.POLLIN}}
+		n, err := unix.Poll(fds, keyPollTimeoutMs)
+		if errors.Is(err, unix.EINTR) || n == 0 {
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		read, err := r.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		data := make([]byte, read)
+		copy(data, buf[:read])
+		select {
+		case r.keys <- data:
+		case <-r.done:
+			return
+		}
+	}
+}
+
+func (r *keyReader) Keys() <-chan []byte {
+	return r.keys
+}
+
+// Pause stops reading until Resume, keys which were not handled yet are
+// dropped.
+func (r *keyReader) Pause() error {
+	ack := make(chan struct{})
+	for {
+		select {
+		case r.pause <- ack:
+			<-ack
+			return nil
+		case <-r.keys:
+		}
+	}
+}
+
+func (r *keyReader) Resume() {
+	r.resume <- struct{}{}
+}
+
+func (r *keyReader) Close() {
+	close(r.done)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"homecontroller/secret"
+
+	"github.com/op/go-logging"
+	"golang.org/x/term"
+)
+
+var log = logging.MustGetLogger("base")
+
+// stdinReader is shared, so that input buffered by one prompt is not lost
+// for the next one.
+var stdinReader = bufio.NewReader(os.Stdin)
+
+func readPassword() (string, error) {
+	var rawInput string
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		pwBytes, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println() // ReadPassword doesn't print newline
+		if err != nil {
+			return "", err
+		}
+
+		rawInput = string(pwBytes)
+
+	} else {
+		input, err := stdinReader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+
+		rawInput = input
+	}
+
+	return strings.TrimSpace(rawInput), nil
+}
+
+// promptFromTerminal asks user for secret without echoing it.
+func promptFromTerminal(question string) (string, error) {
+	fmt.Print(question)
+	return readPassword()
+}
+
+// resolveSecretFlag returns value of flag, which may be secret reference, or
+// value of environment variable when flag is empty.
+func resolveSecretFlag(name string, value string, envName string) string {
+	resolved, err := secretFlagValue(name, value, envName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return resolved
+}
+
+func secretFlagValue(name string, value string, envName string) (string, error) {
+	if value == "" {
+		return os.Getenv(envName), nil
+	}
+
+	ref := secret.Ref(value)
+	if !ref.IsReference() && flagFromCommandLine(name) {
+		log.Warningf("Value of --%s is visible in process list, use reference like env:VAR or %s instead", name, envName)
+	}
+
+	resolved, err := ref.Resolve()
+	if err != nil {
+		return "", fmt.Errorf("could not resolve %s: %v", name, err)
+	}
+
+	return resolved, nil
+}
+
+// commandLineFlags are flags given on command line, recorded before flags
+// are set from config file.
+var commandLineFlags = make(map[string]bool)
+
+func recordCommandLineFlags() {
+	flag.Visit(func(f *flag.Flag) {
+		commandLineFlags[f.Name] = true
+	})
+}
+
+// flagFromCommandLine reports whether flag was given on command line, not
+// taken from config file.
+func flagFromCommandLine(name string) bool {
+	return commandLineFlags[name]
+}
+
+// confirm asks user a yes/no question, anything else than yes is no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N]: ", question)
+	answer, err := stdinReader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
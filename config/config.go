@@ -0,0 +1,209 @@
+// Package config loads configuration of targets and remote servers from
+// config.yml, see SearchPaths for its location.
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"homecontroller/probing"
+	"homecontroller/secret"
+	"homecontroller/sshctl"
+)
+
+// SshConfiguration of target, values not set here are taken from entry
+// matching target host in ~/.ssh/config. Keys of running ssh-agent are used
+// as well. HostKey pins SHA256 fingerprint of server key, known_hosts is
+// used when it is empty. ProxyJump lists bastion hosts through which the
+// target is reached. Password and passphrase of private key may be secret
+// references, see package secret.
+type SshConfiguration struct {
+	User        string                 `yaml:"user"`
+	Port        *int                   `yaml:"port"`
+	Password    Password               `yaml:"password,omitempty"`
+	PrivateKey  SshPrivateKeyOptions   `yaml:"private_key,omitempty"`
+	Certificate string                 `yaml:"certificate,omitempty"`
+	HostKey     string                 `yaml:"host_key,omitempty"`
+	ProxyJump   []SshJumpConfiguration `yaml:"proxy_jump,omitempty"`
+}
+
+type TargetConfiguration struct {
+	Id               string                          `yaml:"id" schema:"required"`
+	Host             string                          `yaml:"host" schema:"required"`
+	Mac              HwAddress                       `yaml:"mac"`
+	Ssh              SshConfiguration                `yaml:"ssh"`
+	BroadcastAddress []*BroadcastAddress             `yaml:"broadcast_address,omitempty"`
+	WakeTimeout      time.Duration                   `yaml:"wake_timeout,omitempty"`
+	Probe            ProbeConfiguration              `yaml:"probe,omitempty"`
+	Commands         map[string]CommandConfiguration `yaml:"commands,omitempty"`
+	Hooks            HooksConfiguration              `yaml:"hooks,omitempty"`
+}
+
+// ProbeConfiguration selects how online status of target is checked. When
+// mode is empty or auto, mode detected on startup is used. Mode jump connects
+// to ports from the last SSH jump host of target, for targets in network
+// unreachable from the controller.
+type ProbeConfiguration struct {
+	Mode  probing.Mode `yaml:"mode,omitempty"`
+	Ports []int        `yaml:"ports,omitempty"`
+}
+
+// CommandConfiguration is command runnable on target via exec, keyed by name
+// in target commands. Only configured commands can be run.
+type CommandConfiguration struct {
+	Command string        `yaml:"command" schema:"required"`
+	Sudo    bool          `yaml:"sudo,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// HookConfiguration is command run before or after action. It runs on target
+// via SSH, or on the controller itself when local. Failing hook with
+// abort_on_failure stops remaining hooks, and the action for pre hooks.
+type HookConfiguration struct {
+	Command        string        `yaml:"command" schema:"required"`
+	Local          bool          `yaml:"local,omitempty"`
+	Sudo           bool          `yaml:"sudo,omitempty"`
+	Timeout        time.Duration `yaml:"timeout,omitempty"`
+	AbortOnFailure bool          `yaml:"abort_on_failure,omitempty"`
+}
+
+type HooksConfiguration struct {
+	PreHalt  []HookConfiguration `yaml:"pre_halt,omitempty"`
+	PostHalt []HookConfiguration `yaml:"post_halt,omitempty"`
+	PreWake  []HookConfiguration `yaml:"pre_wake,omitempty"`
+	PostWake []HookConfiguration `yaml:"post_wake,omitempty"`
+}
+
+func (t *TargetConfiguration) GetMac() string {
+	return string(t.Mac)
+}
+
+// SshDestination returns SSH connection of target, not resolved against
+// ~/.ssh/config yet.
+func (t *TargetConfiguration) SshDestination() sshctl.Destination {
+	return sshctl.Destination{
+		User:        t.Ssh.User,
+		Host:        t.Host,
+		Port:        t.Ssh.Port,
+		Password:    secret.Ref(t.Ssh.Password),
+		PrivateKey:  t.Ssh.PrivateKey.Key(),
+		Certificate: t.Ssh.Certificate,
+		HostKey:     t.Ssh.HostKey,
+		Jumps:       JumpDestinations(t.Ssh.ProxyJump),
+	}
+}
+
+// ProbeConfig returns probe configuration of target, jump mode probes through
+// SSH jump hosts of the target.
+func (t *TargetConfiguration) ProbeConfig() probing.Config {
+	probe := probing.Config{Mode: t.Probe.Mode, Ports: t.Probe.Ports}
+	if probe.Mode == probing.ModeJump {
+		probe.Jumps = t.SshDestination().Resolve().Jumps
+	}
+
+	return probe
+}
+
+func (t *TargetConfiguration) GetBroadcastAddress() []*BroadcastAddress {
+	return t.BroadcastAddress
+}
+
+// RemoteConfiguration is server through which remote-run runs commands.
+// AuthToken may be secret reference.
+type RemoteConfiguration struct {
+	Id        string                `yaml:"id" schema:"required"`
+	Host      string                `yaml:"host" schema:"required,format=uri"`
+	AuthToken string                `yaml:"auth_token"`
+	Targets   []TargetConfiguration `yaml:"targets"`
+}
+
+// WebhookConfiguration is endpoint receiving events, timestamp and body are
+// signed by Secret, which may be secret reference.
+type WebhookConfiguration struct {
+	Url    string   `yaml:"url" schema:"required,format=uri"`
+	Secret string   `yaml:"secret"`
+	Events []string `yaml:"events,omitempty"`
+}
+
+func (w *WebhookConfiguration) Accepts(eventType string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, eventType)
+}
+
+type WebhooksConfiguration struct {
+	MaxAttempts    int                    `yaml:"max_attempts,omitempty"`
+	DeadLetterPath string                 `yaml:"dead_letter_path,omitempty"`
+	Endpoints      []WebhookConfiguration `yaml:"endpoints"`
+}
+
+// ServerConfiguration sets flags of the HTTP server, keys are names of the
+// flags. Flags given on command line take precedence.
+type ServerConfiguration struct {
+	AuthToken           *string        `yaml:"auth_token,omitempty"`
+	HttpAddr            *string        `yaml:"http_addr,omitempty"`
+	HttpsAddr           *string        `yaml:"https_addr,omitempty"`
+	HttpsCert           *string        `yaml:"https_cert,omitempty"`
+	HttpsKey            *string        `yaml:"https_key,omitempty"`
+	Dashboard           *bool          `yaml:"dashboard,omitempty"`
+	MqttBroker          *string        `yaml:"mqtt_broker,omitempty"`
+	MqttClientId        *string        `yaml:"mqtt_client_id,omitempty"`
+	MqttUser            *string        `yaml:"mqtt_user,omitempty"`
+	MqttPassword        *string        `yaml:"mqtt_password,omitempty"`
+	MqttTopicPrefix     *string        `yaml:"mqtt_topic_prefix,omitempty"`
+	MqttDiscoveryPrefix *string        `yaml:"mqtt_discovery_prefix,omitempty"`
+	SshIdleTimeout      *time.Duration `yaml:"ssh_idle_timeout,omitempty"`
+	JobRetention        *time.Duration `yaml:"job_retention,omitempty"`
+	KnownHosts          *string        `yaml:"known_hosts,omitempty"`
+	AdhocSsh            *bool          `yaml:"adhoc_ssh,omitempty"`
+}
+
+// Flags returns values set in server configuration keyed by flag name.
+func (s ServerConfiguration) Flags() map[string]string {
+	flags := make(map[string]string)
+	value := reflect.ValueOf(s)
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		if field.IsNil() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(value.Type().Field(i).Tag.Get("yaml"), ",")
+		flags[name] = fmt.Sprint(field.Elem().Interface())
+	}
+
+	return flags
+}
+
+// LocalConfiguration is content of config file. Files listed in Include are
+// merged into it, their targets, remotes and webhook endpoints are appended.
+type LocalConfiguration struct {
+	Include    []string              `yaml:"include,omitempty"`
+	Server     ServerConfiguration   `yaml:"server,omitempty"`
+	RunTargets []TargetConfiguration `yaml:"run_targets"`
+	Remote     []RemoteConfiguration `yaml:"remote"`
+	Webhooks   WebhooksConfiguration `yaml:"webhooks,omitempty"`
+
+	// Sources are files and include directories the configuration was read
+	// from.
+	Sources []string `yaml:"-"`
+}
+
+func (c *LocalConfiguration) RemoteById(id string) *RemoteConfiguration {
+	for i := range c.Remote {
+		if c.Remote[i].Id == id {
+			return &c.Remote[i]
+		}
+	}
+	return nil
+}
+
+func TargetById(targets []TargetConfiguration, id string) *TargetConfiguration {
+	for i := range targets {
+		if targets[i].Id == id {
+			return &targets[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is config file opened for editing. Only edited values are changed,
+// comments and ${VAR} references of the rest are kept.
+type File struct {
+	Path     string
+	Original []byte
+
+	document yaml.Node
+	changed  bool
+}
+
+// OpenFile parses config file for editing, missing file is empty.
+func OpenFile(path string) (*File, error) {
+	file := &File{Path: path}
+
+	bts, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("couldnt read config file '%s', %v", path, err)
+	}
+	file.Original = bts
+
+	if err := yaml.Unmarshal(bts, &file.document); err != nil {
+		return nil, fmt.Errorf("couldnt parse config file '%s' %v", path, err)
+	}
+	if file.document.Kind == 0 {
+		file.document = yaml.Node{Kind: yaml.DocumentNode}
+	}
+	if len(file.document.Content) == 0 {
+		file.document.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	if file.root().Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config file '%s' must be a mapping", path)
+	}
+
+	return file, nil
+}
+
+func (f *File) root() *yaml.Node {
+	return f.document.Content[0]
+}
+
+// runTargets returns run_targets list of the file, created when create is
+// set.
+func (f *File) runTargets(create bool) *yaml.Node {
+	root := f.root()
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "run_targets" {
+			continue
+		}
+
+		value := root.Content[i+1]
+		if value.Kind != yaml.SequenceNode && create {
+			*value = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		}
+		return value
+	}
+
+	if !create {
+		return nil
+	}
+
+	targets := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "run_targets"}, targets)
+	return targets
+}
+
+func (f *File) findTarget(id string) *yaml.Node {
+	targets := f.runTargets(false)
+	if targets == nil || targets.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	for _, target := range targets.Content {
+		if value := mappingValue(target, "id"); value != nil && value.Value == id {
+			return target
+		}
+	}
+
+	return nil
+}
+
+// HasTarget reports whether run target with id is defined in this file.
+func (f *File) HasTarget(id string) bool {
+	return f.findTarget(id) != nil
+}
+
+// AddTarget appends run target, empty fields are left out.
+func (f *File) AddTarget(target TargetConfiguration) error {
+	node, err := compactNode(target)
+	if err != nil {
+		return err
+	}
+
+	targets := f.runTargets(true)
+	targets.Content = append(targets.Content, node)
+	f.changed = true
+	return nil
+}
+
+// FillTarget sets fields of run target with the same id which are missing
+// or empty in the file, other fields are kept.
+func (f *File) FillTarget(target TargetConfiguration) error {
+	existing := f.findTarget(target.Id)
+	if existing == nil {
+		return fmt.Errorf("run target '%s' is not defined in config file '%s'", target.Id, f.Path)
+	}
+
+	node, err := compactNode(target)
+	if err != nil {
+		return err
+	}
+
+	if fillNode(existing, node) {
+		f.changed = true
+	}
+	return nil
+}
+
+// Changed reports whether targets were added or filled.
+func (f *File) Changed() bool {
+	return f.changed
+}
+
+// Bytes returns edited content of the file.
+func (f *File) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&f.document); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Save writes edited content, the file is replaced at once so that it is
+// never half written.
+func (f *File) Save() error {
+	bts, err := f.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0700); err != nil {
+		return fmt.Errorf("couldnt create directory of %s, %v", f.Path, err)
+	}
+
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(f.Path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmpPath := f.Path + ".tmp"
+	if err := os.WriteFile(tmpPath, bts, mode); err != nil {
+		return fmt.Errorf("couldnt write config file '%s', %v", f.Path, err)
+	}
+
+	return os.Rename(tmpPath, f.Path)
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// compactNode encodes value to node without empty fields.
+func compactNode(value interface{}) (*yaml.Node, error) {
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return nil, err
+	}
+
+	removeEmpty(&node)
+	return &node, nil
+}
+
+// removeEmpty removes fields with empty value from mappings and reports
+// whether node itself is empty.
+func removeEmpty(node *yaml.Node) bool {
+	switch node.Kind {
+	case yaml.MappingNode:
+		var content []*yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if !removeEmpty(node.Content[i+1]) {
+				content = append(content, node.Content[i], node.Content[i+1])
+			}
+		}
+		node.Content = content
+		return len(content) == 0
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			removeEmpty(item)
+		}
+		return len(node.Content) == 0
+	case yaml.ScalarNode:
+		return isEmptyScalar(node)
+	}
+
+	return false
+}
+
+func isEmptyScalar(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && (node.ShortTag() == "!!null" || (node.ShortTag() == "!!str" && node.Value == ""))
+}
+
+// fillNode adds fields of src missing or empty in dst and reports whether
+// any was added.
+func fillNode(dst *yaml.Node, src *yaml.Node) bool {
+	filled := false
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+
+		existing := mappingValue(dst, key.Value)
+		switch {
+		case existing == nil:
+			dst.Content = append(dst.Content, key, value)
+			filled = true
+		case existing.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+			filled = fillNode(existing, value) || filled
+		case isEmptyScalar(existing):
+			*existing = *value
+			filled = true
+		}
+	}
+
+	return filled
+}
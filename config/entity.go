@@ -0,0 +1,92 @@
+package config
+
+import (
+	"errors"
+	"net"
+
+	"homecontroller/secret"
+	"homecontroller/sshctl"
+)
+
+type BroadcastAddress struct {
+	Ip   IP  `json:"ip" yaml:"ip" schema:"required"`
+	Port int `json:"port" yaml:"port"`
+}
+
+func (a *BroadcastAddress) Validate() error {
+	return a.Ip.Validate()
+}
+
+type Password string
+
+func (p Password) Validate() error {
+	if len(p) == 0 {
+		return errors.New("password must not be empty")
+	}
+
+	return nil
+}
+
+type HwAddress string
+
+func (a HwAddress) Validate() error {
+	_, err := net.ParseMAC(string(a))
+	return err
+}
+
+type IP string
+
+func (i IP) Validate() error {
+	ip := net.ParseIP(string(i))
+	if ip == nil {
+		return errors.New("invalid ip")
+	}
+
+	return nil
+}
+
+type SshPrivateKeyOptions struct {
+	Path       string `json:"path,required" yaml:"path" schema:"required"`
+	Passphrase string `json:"passphrase" yaml:"passphrase"`
+}
+
+// SshJumpConfiguration is one hop of proxy_jump chain. Hops are dialed in
+// order and the target is reached from the last one.
+type SshJumpConfiguration struct {
+	Host        string               `json:"host,required" yaml:"host" schema:"required"`
+	User        string               `json:"user,omitempty" yaml:"user,omitempty"`
+	Port        *int                 `json:"port,omitempty" yaml:"port,omitempty"`
+	Password    Password             `json:"password,omitempty" yaml:"password,omitempty"`
+	PrivateKey  SshPrivateKeyOptions `json:"private_key,omitempty" yaml:"private_key,omitempty"`
+	Certificate string               `json:"certificate,omitempty" yaml:"certificate,omitempty"`
+	HostKey     string               `json:"host_key,omitempty" yaml:"host_key,omitempty"`
+}
+
+func (k *SshPrivateKeyOptions) Validate() error {
+	if len(k.Path) == 0 {
+		return errors.New("path must not be empty")
+	}
+
+	return nil
+}
+
+func (k SshPrivateKeyOptions) Key() *sshctl.PrivateKey {
+	return &sshctl.PrivateKey{Path: k.Path, Passphrase: secret.Ref(k.Passphrase)}
+}
+
+func JumpDestinations(jumps []SshJumpConfiguration) []sshctl.Destination {
+	var destinations []sshctl.Destination
+	for _, jump := range jumps {
+		destinations = append(destinations, sshctl.Destination{
+			User:        jump.User,
+			Host:        jump.Host,
+			Port:        jump.Port,
+			Password:    secret.Ref(jump.Password),
+			PrivateKey:  jump.PrivateKey.Key(),
+			Certificate: jump.Certificate,
+			HostKey:     jump.HostKey,
+		})
+	}
+
+	return destinations
+}
@@ -0,0 +1,244 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/op/go-logging"
+	"gopkg.in/yaml.v3"
+)
+
+var log = logging.MustGetLogger("config")
+
+const configFileName = "config.yml"
+
+// ErrNotFound is returned by Load when config file is in none of search paths.
+var ErrNotFound = errors.New("config file not found")
+
+var explicitPath string
+
+// SetPath sets config file given by --config flag, search paths are not used
+// then.
+func SetPath(path string) {
+	explicitPath = path
+}
+
+// SearchPaths returns config files in order in which Load tries them. File
+// set by SetPath or HOMECONTROLLER_CONFIG is the only one when given.
+func SearchPaths() []string {
+	if explicitPath != "" {
+		return []string{explicitPath}
+	}
+	if path := os.Getenv("HOMECONTROLLER_CONFIG"); path != "" {
+		return []string{path}
+	}
+
+	var paths []string
+	// XDG_CONFIG_HOME or ~/.config on Linux
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "homecontroller", configFileName))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".homecontroller", configFileName))
+	}
+	if runtime.GOOS != "windows" {
+		paths = append(paths, filepath.Join("/etc/homecontroller", configFileName))
+	}
+
+	return paths
+}
+
+// findPath returns the first of search paths which exists, empty when there
+// is none.
+func findPath() string {
+	paths := SearchPaths()
+	if explicitPath != "" || os.Getenv("HOMECONTROLLER_CONFIG") != "" {
+		return paths[0]
+	}
+
+	for _, path := range paths {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// PrintPath returns config file which Load reads, or all search paths when
+// there is no config file.
+func PrintPath() string {
+	if path := findPath(); path != "" {
+		return path
+	}
+
+	return strings.Join(SearchPaths(), ", ")
+}
+
+func Load() (*LocalConfiguration, error) {
+	path := findPath()
+	if path == "" {
+		return nil, fmt.Errorf("%w, tried %s", ErrNotFound, PrintPath())
+	}
+
+	config, err := loadFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file '%s', %v", path, err)
+	}
+
+	return config, nil
+}
+
+// loadFile reads config file together with files it includes. Parents are
+// files including this one, to detect include cycles.
+func loadFile(path string, parents []string) (*LocalConfiguration, error) {
+	if absPath, err := filepath.Abs(path); err == nil {
+		path = absPath
+	}
+	if slices.Contains(parents, path) {
+		return nil, fmt.Errorf("config file '%s' includes itself", path)
+	}
+
+	bts, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldnt read config file '%s', %v", path, err)
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(bts, &document); err != nil {
+		return nil, fmt.Errorf("couldnt parse config file '%s' %v", path, err)
+	}
+
+	config := LocalConfiguration{Sources: []string{path}}
+	if document.Kind == 0 {
+		return &config, nil
+	}
+
+	if err := expandNode(&document); err != nil {
+		return nil, fmt.Errorf("couldnt parse config file '%s' %v", path, err)
+	}
+	errs, warnings := configSchema().validateDocument(&document)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid config file '%s'\n%w", path, errors.Join(errs...))
+	}
+	for _, warning := range warnings {
+		log.Warningf("Config file '%s', %v", path, warning)
+	}
+	if err := document.Decode(&config); err != nil {
+		return nil, fmt.Errorf("couldnt parse config file '%s' %v", path, err)
+	}
+
+	for _, pattern := range config.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include '%s' in config file '%s', %v", pattern, path, err)
+		}
+		if strings.ContainsAny(pattern, "*?[") {
+			// directory changes when matching file is added or removed
+			config.Sources = append(config.Sources, filepath.Dir(pattern))
+		} else if len(matches) == 0 {
+			// missing file which is not a pattern is reported by read
+			matches = []string{pattern}
+		}
+
+		for _, match := range matches {
+			included, err := loadFile(match, append(parents, path))
+			if err != nil {
+				return nil, err
+			}
+			if len(included.Server.Flags()) > 0 {
+				return nil, fmt.Errorf("config file '%s' is included, server section is allowed only in main config file", match)
+			}
+
+			config.Sources = append(config.Sources, included.Sources...)
+			config.RunTargets = append(config.RunTargets, included.RunTargets...)
+			config.Remote = append(config.Remote, included.Remote...)
+			config.Webhooks.Endpoints = append(config.Webhooks.Endpoints, included.Webhooks.Endpoints...)
+		}
+	}
+
+	return &config, nil
+}
+
+// expandNode replaces ${VAR} in scalar values by environment variables, keys
+// of mappings are kept as written.
+func expandNode(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		value, err := expandEnv(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", node.Line, err)
+		}
+
+		if value != node.Value {
+			node.Value = value
+			// plain scalar is resolved again, so that ${PORT} may be number
+			if node.Style == 0 {
+				node.Tag = ""
+			}
+		}
+		return nil
+	}
+
+	for i, child := range node.Content {
+		// content of mapping alternates keys and values
+		if node.Kind == yaml.MappingNode && i%2 == 0 {
+			continue
+		}
+		if err := expandNode(child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expandEnv replaces ${VAR} and ${VAR:-default} in value, $${ is kept as
+// literal ${. Variable without default must be set.
+func expandEnv(value string) (string, error) {
+	var result strings.Builder
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			result.WriteString(value)
+			return result.String(), nil
+		}
+
+		if start > 0 && value[start-1] == '$' {
+			result.WriteString(value[:start-1])
+			result.WriteString("${")
+			value = value[start+2:]
+			continue
+		}
+
+		end := strings.IndexByte(value[start:], '}')
+		if end < 0 {
+			return "", errors.New("unterminated ${ in value")
+		}
+
+		name, fallback, hasFallback := strings.Cut(value[start+2:start+end], ":-")
+		env, ok := os.LookupEnv(name)
+		if !ok || (env == "" && hasFallback) {
+			if !hasFallback {
+				return "", fmt.Errorf("environment variable %s is not set", name)
+			}
+			env = fallback
+		}
+
+		result.WriteString(value[:start])
+		result.WriteString(env)
+		value = value[start+end+1:]
+	}
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"homecontroller/probing"
+)
+
+const durationPattern = `^(0|-?([0-9]+(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+)$`
+
+var durationRegexp = regexp.MustCompile(durationPattern)
+
+// JsonSchema is JSON Schema (draft-07) of config file. Editors use it for
+// completion and inline errors, Load validates config files against it.
+type JsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	MinLength            int                    `json:"minLength,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	AnyOf                []*JsonSchema          `json:"anyOf,omitempty"`
+	Items                *JsonSchema            `json:"items,omitempty"`
+	Properties           map[string]*JsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Definitions          map[string]*JsonSchema `json:"definitions,omitempty"`
+
+	// pattern is compiled Pattern, so that it is not compiled per value
+	pattern *regexp.Regexp `json:"-"`
+}
+
+// Schema returns JSON Schema generated from LocalConfiguration. Fields are
+// named by yaml tags, schema tag marks required fields and format of value,
+// e.g. `schema:"required,format=uri"`.
+func Schema() *JsonSchema {
+	definitions := make(map[string]*JsonSchema)
+	schema := structSchema(reflect.TypeOf(LocalConfiguration{}), definitions)
+	schema.Schema = "http://json-schema.org/draft-07/schema#"
+	schema.Title = "homecontroller configuration"
+	schema.Definitions = definitions
+	return schema
+}
+
+func typeSchema(t reflect.Type, definitions map[string]*JsonSchema) *JsonSchema {
+	switch t {
+	case reflect.TypeOf(HwAddress("")):
+		// format is checked by HwAddress.Validate, which accepts every form
+		// of net.ParseMAC
+		return &JsonSchema{Type: "string", Format: "mac"}
+	case reflect.TypeOf(IP("")):
+		return &JsonSchema{Type: "string", AnyOf: []*JsonSchema{{Format: "ipv4"}, {Format: "ipv6"}}}
+	case reflect.TypeOf(time.Duration(0)):
+		return &JsonSchema{Type: "string", Pattern: durationPattern, pattern: durationRegexp}
+	case reflect.TypeOf(probing.Mode("")):
+		var modes []string
+		for _, mode := range []probing.Mode{probing.ModeAuto, probing.ModePrivileged, probing.ModeUnprivileged, probing.ModeTcp, probing.ModeArp, probing.ModeJump} {
+			modes = append(modes, string(mode))
+		}
+		return &JsonSchema{Type: "string", Enum: modes}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem(), definitions)
+	case reflect.Struct:
+		// named structs are definitions, so that editors show their names
+		if _, ok := definitions[t.Name()]; !ok {
+			definitions[t.Name()] = &JsonSchema{}
+			*definitions[t.Name()] = *structSchema(t, definitions)
+		}
+		return &JsonSchema{Ref: "#/definitions/" + t.Name()}
+	case reflect.Slice:
+		return &JsonSchema{Type: "array", Items: typeSchema(t.Elem(), definitions)}
+	case reflect.Map:
+		return &JsonSchema{Type: "object", AdditionalProperties: typeSchema(t.Elem(), definitions)}
+	case reflect.String:
+		return &JsonSchema{Type: "string"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &JsonSchema{Type: "integer"}
+	case reflect.Bool:
+		return &JsonSchema{Type: "boolean"}
+	}
+
+	// type without schema accepts any value
+	return &JsonSchema{}
+}
+
+func structSchema(t reflect.Type, definitions map[string]*JsonSchema) *JsonSchema {
+	schema := &JsonSchema{
+		Type:                 "object",
+		Properties:           make(map[string]*JsonSchema),
+		AdditionalProperties: false,
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+
+		property := typeSchema(field.Type, definitions)
+		for _, option := range strings.Split(field.Tag.Get("schema"), ",") {
+			switch {
+			case option == "required":
+				schema.Required = append(schema.Required, name)
+				if property.Type == "string" {
+					property.MinLength = 1
+				}
+			case strings.HasPrefix(option, "format="):
+				property.Format = strings.TrimPrefix(option, "format=")
+			}
+		}
+
+		schema.Properties[name] = property
+	}
+
+	return schema
+}
@@ -0,0 +1,237 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+var configSchema = sync.OnceValue(Schema)
+
+// SchemaError is value of config file which does not match schema.
+type SchemaError struct {
+	Line    int
+	Column  int
+	Path    string
+	Message string
+
+	// warning does not make config file invalid
+	warning bool
+}
+
+func (e *SchemaError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+	}
+
+	return fmt.Sprintf("line %d, column %d: %s: %s", e.Line, e.Column, e.Path, e.Message)
+}
+
+var formatNames = map[string]string{
+	"mac":  "MAC address",
+	"ipv4": "IPv4 address",
+	"ipv6": "IPv6 address",
+	"uri":  "URL",
+}
+
+func formatValid(format string, value string) bool {
+	switch format {
+	case "mac":
+		return HwAddress(value).Validate() == nil
+	case "ipv4":
+		ip := net.ParseIP(value)
+		return ip != nil && ip.To4() != nil
+	case "ipv6":
+		ip := net.ParseIP(value)
+		return ip != nil && ip.To4() == nil
+	case "uri":
+		u, err := url.ParseRequestURI(value)
+		return err == nil && u.Scheme != "" && u.Host != ""
+	default:
+		return true
+	}
+}
+
+// validateDocument checks parsed config file against schema, errors are
+// ordered by their position in the file. Unknown fields are only warnings,
+// so that config files with keys of other versions still load.
+func (s *JsonSchema) validateDocument(document *yaml.Node) (errs []error, warnings []error) {
+	if document.Kind != yaml.DocumentNode || len(document.Content) == 0 {
+		return nil, nil
+	}
+
+	schemaErrors := s.validateNode(document.Content[0], "", s.Definitions)
+	slices.SortStableFunc(schemaErrors, func(a, b *SchemaError) int {
+		return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+
+	for _, err := range schemaErrors {
+		if err.warning {
+			warnings = append(warnings, err)
+		} else {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs, warnings
+}
+
+func (s *JsonSchema) validateNode(node *yaml.Node, path string, definitions map[string]*JsonSchema) []*SchemaError {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if s.Ref != "" {
+		return definitions[strings.TrimPrefix(s.Ref, "#/definitions/")].validateNode(node, path, definitions)
+	}
+	// empty value is zero value of field
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null" {
+		return nil
+	}
+
+	fail := func(format string, args ...interface{}) []*SchemaError {
+		return []*SchemaError{{Line: node.Line, Column: node.Column, Path: path, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	switch s.Type {
+	case "":
+		// schema without type accepts any value
+		if len(s.AnyOf) == 0 {
+			return nil
+		}
+	case "object":
+		if node.Kind != yaml.MappingNode {
+			return fail("must be a mapping")
+		}
+
+		var errs []*SchemaError
+		present := make(map[string]bool)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			present[key.Value] = true
+
+			property, ok := s.Properties[key.Value]
+			if !ok {
+				additional, isSchema := s.AdditionalProperties.(*JsonSchema)
+				if !isSchema {
+					errs = append(errs, &SchemaError{Line: key.Line, Column: key.Column, Path: path, Message: fmt.Sprintf("unknown field '%s'", key.Value), warning: true})
+					continue
+				}
+				property = additional
+			}
+
+			propertyPath := key.Value
+			if path != "" {
+				propertyPath = path + "." + key.Value
+			}
+			errs = append(errs, property.validateNode(value, propertyPath, definitions)...)
+		}
+
+		for _, name := range s.Required {
+			if !present[name] {
+				errs = append(errs, fail("missing required field '%s'", name)...)
+			}
+		}
+		return errs
+	case "array":
+		if node.Kind != yaml.SequenceNode {
+			return fail("must be a list")
+		}
+
+		var errs []*SchemaError
+		for i, item := range node.Content {
+			errs = append(errs, s.Items.validateNode(item, fmt.Sprintf("%s[%d]", path, i), definitions)...)
+		}
+		return errs
+	}
+
+	if node.Kind != yaml.ScalarNode {
+		return fail("must be a %s", s.Type)
+	}
+	if (s.Type == "integer" && node.ShortTag() != "!!int") || (s.Type == "boolean" && node.ShortTag() != "!!bool") {
+		return fail("'%s' is not %s", node.Value, s.Type)
+	}
+	if message := s.validateValue(node.Value); message != "" {
+		return fail("%s", message)
+	}
+
+	return nil
+}
+
+// validateValue returns what is wrong with scalar value, empty when it is
+// valid.
+func (s *JsonSchema) validateValue(value string) string {
+	if len(value) < s.MinLength {
+		return "must not be empty"
+	}
+	// optional value may be left empty
+	if value == "" {
+		return ""
+	}
+	if len(s.Enum) > 0 && !slices.Contains(s.Enum, value) {
+		return fmt.Sprintf("'%s' must be one of %s", value, strings.Join(s.Enum, ", "))
+	}
+	if s.Format != "" && !formatValid(s.Format, value) {
+		return fmt.Sprintf("'%s' is not valid %s", value, formatNames[s.Format])
+	}
+	if s.pattern != nil && !s.pattern.MatchString(value) {
+		if s.Pattern == durationPattern {
+			return fmt.Sprintf("'%s' is not valid duration, e.g. 30s or 5m", value)
+		}
+		if s.Format != "" {
+			return fmt.Sprintf("'%s' is not valid %s", value, formatNames[s.Format])
+		}
+		return fmt.Sprintf("'%s' does not match %s", value, s.Pattern)
+	}
+
+	if len(s.AnyOf) > 0 {
+		var names []string
+		for _, option := range s.AnyOf {
+			if option.validateValue(value) == "" {
+				return ""
+			}
+			names = append(names, formatNames[option.Format])
+		}
+		return fmt.Sprintf("'%s' is not valid %s", value, strings.Join(names, " or "))
+	}
+
+	return ""
+}
+
+// Validate checks what schema cannot, that identifiers are unique.
+func (c *LocalConfiguration) Validate() error {
+	if err := validateUniqueTargets("run target", c.RunTargets); err != nil {
+		return err
+	}
+
+	remoteIds := make(map[string]bool)
+	for _, remote := range c.Remote {
+		if remoteIds[remote.Id] {
+			return fmt.Errorf("remote '%s' is defined more than once", remote.Id)
+		}
+		remoteIds[remote.Id] = true
+
+		if err := validateUniqueTargets(fmt.Sprintf("target of remote '%s'", remote.Id), remote.Targets); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateUniqueTargets(kind string, targets []TargetConfiguration) error {
+	ids := make(map[string]bool)
+	for _, target := range targets {
+		if ids[target.Id] {
+			return fmt.Errorf("%s '%s' is defined more than once", kind, target.Id)
+		}
+		ids[target.Id] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+)
+
+// Snapshot records modification time and size of config files, polling
+// compares snapshots to detect change of configuration.
+type Snapshot map[string]string
+
+func TakeSnapshot(paths []string) Snapshot {
+	snapshot := make(Snapshot, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			snapshot[path] = "missing"
+			continue
+		}
+
+		snapshot[path] = fmt.Sprintf("%d %d", info.ModTime().UnixNano(), info.Size())
+	}
+
+	return snapshot
+}
+
+func (s Snapshot) Equal(other Snapshot) bool {
+	return maps.Equal(s, other)
+}
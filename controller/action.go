@@ -0,0 +1,327 @@
+// Package controller wakes, halts and runs commands on configured targets,
+// running their hooks and recording every action as metric and event.
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"homecontroller/config"
+	"homecontroller/events"
+	"homecontroller/metrics"
+	"homecontroller/probing"
+	"homecontroller/sshctl"
+	"homecontroller/wol"
+
+	"github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("controller")
+
+type MagicPacket interface {
+	GetMac() string
+	GetBroadcastAddress() []*config.BroadcastAddress
+}
+
+// Error carries a short machine readable reason of an action failure, used
+// as a metric label.
+type Error struct {
+	Err    error
+	Reason string
+}
+
+func (e Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
+// FailureReason returns machine readable reason of action failure.
+func FailureReason(err error) string {
+	var actErr Error
+	if errors.As(err, &actErr) {
+		return actErr.Reason
+	}
+
+	var sshErr sshctl.Error
+	if errors.As(err, &sshErr) {
+		return sshErr.Reason
+	}
+
+	var wolErr wol.Error
+	if errors.As(err, &wolErr) {
+		return wolErr.Reason
+	}
+
+	return "unknown"
+}
+
+type ExecResult struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+}
+
+type HookResult struct {
+	Hook  string `json:"hook"`
+	Local bool   `json:"local"`
+	ExecResult
+	Error string `json:"error,omitempty"`
+}
+
+type ActionResult struct {
+	Target string       `json:"target"`
+	Hooks  []HookResult `json:"hooks,omitempty"`
+}
+
+// SendMagicPacket sends magic packet to broadcast addresses of p.
+func SendMagicPacket(ctx context.Context, p MagicPacket) error {
+	var addresses []wol.Address
+	for _, address := range p.GetBroadcastAddress() {
+		addresses = append(addresses, wol.Address{Ip: string(address.Ip), Port: address.Port})
+	}
+
+	return wol.Send(ctx, p.GetMac(), addresses)
+}
+
+// Wake runs pre_wake hooks, sends magic packet and runs post_wake hooks
+// once target comes online. Target is locked only until the packet is sent,
+// waiting for it and post_wake hooks do not block other actions.
+func Wake(ctx context.Context, target *config.TargetConfiguration, prompt sshctl.PromptFunc) (ActionResult, error) {
+	result := ActionResult{Target: target.Id}
+
+	finish, err := BeginAction(TargetKey(target), "wake")
+	if err != nil {
+		return result, err
+	}
+	defer finish()
+
+	hooks, err := runHooks(ctx, target, hookPreWake, target.Hooks.PreWake, prompt)
+	result.Hooks = append(result.Hooks, hooks...)
+	if err != nil {
+		recordTargetAction("wake", target, err)
+		return result, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	ReportProgress(ctx, "sending magic packet")
+	err = SendMagicPacket(ctx, target)
+	recordTargetAction("wake", target, err)
+	finish()
+	if err != nil {
+		return result, err
+	}
+
+	if len(target.Hooks.PostWake) == 0 {
+		if target.WakeTimeout > 0 {
+			VerifyWake(serverCtx, target.Id, target.Host, target.ProbeConfig(), target.WakeTimeout)
+		}
+		return result, nil
+	}
+
+	timeout := target.WakeTimeout
+	if timeout <= 0 {
+		timeout = defaultPostWakeTimeout
+	}
+
+	ReportProgress(ctx, "waiting for target to come online")
+	if !WaitOnline(ctx, target.Id, target.Host, target.ProbeConfig(), timeout) {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		result.Hooks = append(result.Hooks, skippedHooks(hookPostWake, target.Hooks.PostWake, fmt.Sprintf("target did not come online within %v", timeout))...)
+		return result, nil
+	}
+
+	hooks, _ = runHooks(ctx, target, hookPostWake, target.Hooks.PostWake, prompt)
+	result.Hooks = append(result.Hooks, hooks...)
+	return result, nil
+}
+
+// serverCtx is cancelled when server shuts down, see SetServerContext.
+var serverCtx = context.Background()
+
+// SetServerContext sets context of server, wake verification started by
+// actions stops once it is cancelled. It must be called before any action.
+func SetServerContext(ctx context.Context) {
+	serverCtx = ctx
+}
+
+// VerifyWake waits in background until host comes online or ctx is
+// cancelled and publishes the outcome as event. Ctx should outlive the
+// request, e.g. context of server.
+func VerifyWake(ctx context.Context, target string, host string, probe probing.Config, timeout time.Duration) {
+	go WaitOnline(ctx, target, host, probe, timeout)
+}
+
+// WaitOnline blocks until host comes online, timeout passes or ctx is
+// cancelled. The outcome is published as event.
+func WaitOnline(ctx context.Context, target string, host string, probe probing.Config, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		isOnline, err := probing.Online(ctx, host, probe)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			log.Warningf("Could not verify wake of %s: %v", target, err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		if isOnline {
+			events.Publish(events.EventWakeVerified, target, nil)
+			return true
+		}
+	}
+
+	if ctx.Err() != nil {
+		return false
+	}
+
+	log.Warningf("Target %s did not come online within %v after wake", target, timeout)
+	events.Publish(events.EventWakeTimeout, target, nil)
+	return false
+}
+
+// Halt runs pre_halt hooks, halts target and runs post_halt hooks.
+func Halt(ctx context.Context, target *config.TargetConfiguration, prompt sshctl.PromptFunc) (ActionResult, error) {
+	result := ActionResult{Target: target.Id}
+
+	finish, err := BeginAction(TargetKey(target), "halt")
+	if err != nil {
+		return result, err
+	}
+	defer finish()
+
+	hooks, err := runHooks(ctx, target, hookPreHalt, target.Hooks.PreHalt, prompt)
+	result.Hooks = append(result.Hooks, hooks...)
+	if err != nil {
+		recordTargetAction("halt", target, err)
+		return result, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	ReportProgress(ctx, "halting target")
+	err = sshctl.Halt(ctx, target.SshDestination(), prompt)
+	recordTargetAction("halt", target, err)
+	if err != nil {
+		result.Hooks = append(result.Hooks, skippedHooks(hookPostHalt, target.Hooks.PostHalt, "halt failed")...)
+		return result, err
+	}
+
+	hooks, _ = runHooks(ctx, target, hookPostHalt, target.Hooks.PostHalt, prompt)
+	result.Hooks = append(result.Hooks, hooks...)
+	return result, nil
+}
+
+// Reboot restarts target via SSH. Hooks of target are not run, as the target
+// is expected to come back on its own.
+func Reboot(ctx context.Context, target *config.TargetConfiguration, prompt sshctl.PromptFunc) (ActionResult, error) {
+	result := ActionResult{Target: target.Id}
+
+	finish, err := BeginAction(TargetKey(target), "reboot")
+	if err != nil {
+		return result, err
+	}
+	defer finish()
+
+	ReportProgress(ctx, "rebooting target")
+	err = sshctl.Reboot(ctx, target.SshDestination(), prompt)
+	recordTargetAction("reboot", target, err)
+	return result, err
+}
+
+const defaultCommandTimeout = time.Minute
+
+type UnknownCommandError struct {
+	Target string
+	Name   string
+}
+
+func (e UnknownCommandError) Error() string {
+	return fmt.Sprintf("target %s has no command '%s'", e.Target, e.Name)
+}
+
+// Exec runs named command of target. Command exiting with non-zero code is
+// counted as failed action, its output is returned nevertheless.
+func Exec(ctx context.Context, target *config.TargetConfiguration, name string, prompt sshctl.PromptFunc) (ExecResult, error) {
+	command, ok := target.Commands[name]
+	if !ok || command.Command == "" {
+		return ExecResult{}, UnknownCommandError{target.Id, name}
+	}
+
+	finish, err := BeginAction(TargetKey(target), "exec")
+	if err != nil {
+		return ExecResult{}, err
+	}
+	defer finish()
+
+	ReportProgress(ctx, "running command %s", name)
+	result, err := runTargetCommand(ctx, target, command, prompt)
+	actionErr := err
+	if err == nil && result.ExitCode != 0 {
+		actionErr = Error{fmt.Errorf("command %s exited with code %d", name, result.ExitCode), "exit_code"}
+	}
+	recordTargetAction("exec", target, actionErr)
+
+	return result, err
+}
+
+func runTargetCommand(ctx context.Context, target *config.TargetConfiguration, command config.CommandConfiguration, prompt sshctl.PromptFunc) (ExecResult, error) {
+	dest := target.SshDestination().Resolve()
+	cmd := command.Command
+	if command.Sudo && dest.User != "root" {
+		// never wait for password prompt
+		cmd = "sudo -n " + cmd
+	}
+
+	timeout := command.Timeout
+	if timeout <= 0 {
+		timeout = defaultCommandTimeout
+	}
+
+	result, err := sshctl.Run(ctx, dest, cmd, timeout, prompt)
+	return ExecResult(result), err
+}
+
+// RecordAction counts action attempt in metrics under key from ActionKey and
+// publishes it as event of target.
+func RecordAction(action string, target string, key string, err error) {
+	metrics.ObserveAction(action, key, err != nil, FailureReason(err))
+
+	data := events.ActionEventData{}
+	if err != nil {
+		data.Error = err.Error()
+	}
+
+	events.Publish("action."+action, target, data)
+}
+
+func recordTargetAction(action string, target *config.TargetConfiguration, err error) {
+	RecordAction(action, target.Id, TargetKey(target), err)
+}
+
+type progressContextKey struct{}
+
+// WithProgress returns context through which action reports its progress.
+func WithProgress(ctx context.Context, report func(progress string)) context.Context {
+	return context.WithValue(ctx, progressContextKey{}, report)
+}
+
+func ReportProgress(ctx context.Context, format string, args ...interface{}) {
+	if report, ok := ctx.Value(progressContextKey{}).(func(string)); ok {
+		report(fmt.Sprintf(format, args...))
+	}
+}
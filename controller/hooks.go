@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"homecontroller/config"
+	"homecontroller/sshctl"
+)
+
+const (
+	hookPreHalt  = "pre_halt"
+	hookPostHalt = "post_halt"
+	hookPreWake  = "pre_wake"
+	hookPostWake = "post_wake"
+)
+
+const (
+	defaultHookTimeout = 30 * time.Second
+	// defaultPostWakeTimeout is how long post_wake hooks wait for target to
+	// come online when wake_timeout is not configured
+	defaultPostWakeTimeout = 5 * time.Minute
+)
+
+func hookTimeout(h config.HookConfiguration) time.Duration {
+	if h.Timeout <= 0 {
+		return defaultHookTimeout
+	}
+
+	return h.Timeout
+}
+
+// runHooks runs hooks in order and logs their results. Error is returned
+// only when hook with abort_on_failure fails.
+func runHooks(ctx context.Context, target *config.TargetConfiguration, name string, hooks []config.HookConfiguration, prompt sshctl.PromptFunc) ([]HookResult, error) {
+	var results []HookResult
+	for i, hook := range hooks {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		ReportProgress(ctx, "running %s hook %d of %d", name, i+1, len(hooks))
+		result := runHook(ctx, target, name, hook, prompt)
+		results = append(results, result)
+
+		if !result.Failed() {
+			log.Infof("Hook %s of target %s finished", name, target.Id)
+			continue
+		}
+
+		log.Warningf("Hook %s of target %s failed: %s", name, target.Id, result.Describe())
+		if hook.AbortOnFailure {
+			return results, Error{fmt.Errorf("hook %s failed, %s", name, result.Describe()), "hook"}
+		}
+	}
+
+	return results, nil
+}
+
+func runHook(ctx context.Context, target *config.TargetConfiguration, name string, hook config.HookConfiguration, prompt sshctl.PromptFunc) HookResult {
+	result := HookResult{Hook: name, Local: hook.Local}
+
+	var err error
+	if hook.Local {
+		result.ExecResult, err = runLocalCommand(ctx, target, name, hook.Command, hookTimeout(hook))
+	} else {
+		result.ExecResult, err = runTargetCommand(ctx, target, config.CommandConfiguration{
+			Command: hook.Command,
+			Sudo:    hook.Sudo,
+			Timeout: hookTimeout(hook),
+		}, prompt)
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// runLocalCommand runs cmd by shell on the controller. Target is described to
+// the command by environment variables.
+func runLocalCommand(ctx context.Context, target *config.TargetConfiguration, hook string, cmd string, timeout time.Duration) (ExecResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	command := exec.CommandContext(ctx, "sh", "-c", cmd)
+	command.Env = append(os.Environ(),
+		"HOMECONTROLLER_TARGET="+target.Id,
+		"HOMECONTROLLER_HOST="+target.Host,
+		"HOMECONTROLLER_HOOK="+hook,
+	)
+	// do not wait for children holding output open after shell is killed
+	command.WaitDelay = time.Second
+
+	var stdout, stderr bytes.Buffer
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+
+	err := command.Run()
+	result := ExecResult{Stdout: stdout.String(), Stderr: stderr.String()}
+
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		result.ExitCode = -1
+		return result, fmt.Errorf("command did not finish within %v", timeout)
+	case context.Canceled:
+		result.ExitCode = -1
+		return result, ctx.Err()
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	default:
+		result.ExitCode = -1
+		return result, fmt.Errorf("couldnt run command, %v", err)
+	}
+
+	return result, nil
+}
+
+func (r HookResult) Failed() bool {
+	return r.Error != "" || r.ExitCode != 0
+}
+
+func (r HookResult) Describe() string {
+	if r.Error != "" {
+		return r.Error
+	}
+
+	description := fmt.Sprintf("exit code %d", r.ExitCode)
+	if stderr := strings.TrimSpace(r.Stderr); stderr != "" {
+		description += ": " + stderr
+	}
+
+	return description
+}
+
+// skippedHooks reports hooks which were not run.
+func skippedHooks(name string, hooks []config.HookConfiguration, reason string) []HookResult {
+	var results []HookResult
+	for _, hook := range hooks {
+		results = append(results, HookResult{
+			Hook:       name,
+			Local:      hook.Local,
+			ExecResult: ExecResult{ExitCode: -1},
+			Error:      "skipped, " + reason,
+		})
+	}
+
+	return results
+}
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"homecontroller/config"
+)
+
+// targetActions tracks actions running on machines, so that conflicting
+// actions on the same machine are rejected instead of racing each other.
+var targetActions = newActionLocks()
+
+// BusyError is returned when action conflicts with one already running on
+// the same machine.
+type BusyError struct {
+	Action  string
+	Key     string
+	Running string
+}
+
+func (e BusyError) Error() string {
+	return fmt.Sprintf("cannot %s %s, %s is in progress", e.Action, e.Key, e.Running)
+}
+
+// ActionKey identifies machine in action locks, so that requests addressing
+// it by registered target or directly by host or MAC share one lock. It is
+// lower-cased host, or normalized MAC address when host is not known.
+func ActionKey(host string, mac string) string {
+	if host != "" {
+		return strings.ToLower(host)
+	}
+	if hwAddr, err := net.ParseMAC(mac); err == nil {
+		return hwAddr.String()
+	}
+
+	return strings.ToLower(mac)
+}
+
+// TargetKey is ActionKey of registered target.
+func TargetKey(target *config.TargetConfiguration) string {
+	return ActionKey(target.Host, string(target.Mac))
+}
+
+// BeginAction marks action as running on machine identified by key from
+// ActionKey. Returned function marks it finished.
+func BeginAction(key string, action string) (func(), error) {
+	return targetActions.Begin(key, action)
+}
+
+// CheckAction reports whether action could begin now, so that asynchronous
+// requests can be rejected right away.
+func CheckAction(key string, action string) error {
+	return targetActions.Check(key, action)
+}
+
+type actionLocks struct {
+	mu      sync.Mutex
+	running map[string][]string
+}
+
+func newActionLocks() *actionLocks {
+	return &actionLocks{running: make(map[string][]string)}
+}
+
+// actionsConflict reports whether actions may not run at once. Only
+// commands may run in parallel, wake and halt need the machine exclusively.
+func actionsConflict(a, b string) bool {
+	return a != "exec" || b != "exec"
+}
+
+// Check reports whether action could begin now, so that asynchronous
+// requests can be rejected right away.
+func (l *actionLocks) Check(key string, action string) error {
+	key = strings.ToLower(key)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.conflict(key, action)
+}
+
+func (l *actionLocks) conflict(key string, action string) error {
+	for _, running := range l.running[key] {
+		if actionsConflict(running, action) {
+			return BusyError{action, key, running}
+		}
+	}
+
+	return nil
+}
+
+// Begin marks action as running on machine identified by key. Returned
+// function marks it finished.
+func (l *actionLocks) Begin(key string, action string) (func(), error) {
+	key = strings.ToLower(key)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.conflict(key, action); err != nil {
+		return nil, err
+	}
+
+	l.running[key] = append(l.running[key], action)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.finish(key, action)
+		})
+	}, nil
+}
+
+func (l *actionLocks) finish(key string, action string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	running := l.running[key]
+	for i, a := range running {
+		if a == action {
+			running = append(running[:i], running[i+1:]...)
+			break
+		}
+	}
+
+	if len(running) == 0 {
+		delete(l.running, key)
+	} else {
+		l.running[key] = running
+	}
+}
@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"reflect"
+	"sync"
+
+	"homecontroller/config"
+	"homecontroller/events"
+	"homecontroller/probing"
+)
+
+type StatusChangeListener func(target *config.TargetConfiguration, status probing.Status)
+
+// StatusMonitor observes online status of all registered targets in the
+// background and notifies listeners whenever a target changes its state.
+type StatusMonitor struct {
+	mu            sync.Mutex
+	targets       []config.TargetConfiguration
+	states        map[string]probing.Status
+	listeners     []StatusChangeListener
+	subscriptions map[string]*monitorSubscription
+	started       bool
+}
+
+type monitorSubscription struct {
+	target      *config.TargetConfiguration
+	unsubscribe func()
+}
+
+func NewStatusMonitor(targets []config.TargetConfiguration) *StatusMonitor {
+	return &StatusMonitor{
+		targets:       targets,
+		states:        make(map[string]probing.Status),
+		subscriptions: make(map[string]*monitorSubscription),
+	}
+}
+
+func (m *StatusMonitor) OnChange(listener StatusChangeListener) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.listeners = append(m.listeners, listener)
+}
+
+func (m *StatusMonitor) Targets() []config.TargetConfiguration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.targets
+}
+
+func (m *StatusMonitor) Status(targetId string) (probing.Status, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status, ok := m.states[targetId]
+	return status, ok
+}
+
+func (m *StatusMonitor) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.started = true
+	for i := range m.targets {
+		m.subscribe(&m.targets[i])
+	}
+}
+
+func (m *StatusMonitor) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.started = false
+	for id, subscription := range m.subscriptions {
+		subscription.unsubscribe()
+		delete(m.subscriptions, id)
+	}
+}
+
+// SetTargets replaces monitored targets. Observing of added and changed
+// targets starts and of removed targets stops, unchanged targets keep their
+// observation and status.
+func (m *StatusMonitor) SetTargets(targets []config.TargetConfiguration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.targets = targets
+	if !m.started {
+		return
+	}
+
+	current := make(map[string]bool)
+	for i := range targets {
+		target := &targets[i]
+		current[target.Id] = true
+
+		previous, ok := m.subscriptions[target.Id]
+		if ok && reflect.DeepEqual(*previous.target, *target) {
+			continue
+		}
+
+		// new subscription is made first, so that shared observer of
+		// unchanged host keeps running
+		m.subscribe(target)
+		if ok {
+			previous.unsubscribe()
+		}
+	}
+
+	for id, subscription := range m.subscriptions {
+		if !current[id] {
+			subscription.unsubscribe()
+			delete(m.subscriptions, id)
+			delete(m.states, id)
+		}
+	}
+}
+
+// subscribe must be called with mu held.
+func (m *StatusMonitor) subscribe(target *config.TargetConfiguration) {
+	updates, unsubscribe := probing.DefaultHub.Subscribe(target.Host, target.ProbeConfig())
+	subscription := &monitorSubscription{target: target, unsubscribe: unsubscribe}
+	m.subscriptions[target.Id] = subscription
+
+	go func() {
+		for status := range updates {
+			m.update(subscription, status)
+		}
+	}()
+}
+
+func (m *StatusMonitor) update(subscription *monitorSubscription, status probing.Status) {
+	target := subscription.target
+
+	m.mu.Lock()
+	// update of replaced or removed target is dropped
+	if m.subscriptions[target.Id] != subscription {
+		m.mu.Unlock()
+		return
+	}
+
+	previous, known := m.states[target.Id]
+	m.states[target.Id] = status
+	listeners := append([]StatusChangeListener(nil), m.listeners...)
+	m.mu.Unlock()
+
+	if known && previous == status {
+		return
+	}
+
+	log.Infof("Target '%s' online status changed to %v", target.Id, status.IsOnline)
+	if known {
+		eventType := events.EventTargetOffline
+		if status.IsOnline {
+			eventType = events.EventTargetOnline
+		}
+		events.Publish(eventType, target.Id, status)
+	}
+
+	for _, listener := range listeners {
+		listener(target, status)
+	}
+}
@@ -0,0 +1,132 @@
+// Package events publishes target and action events to in-process
+// subscribers, such as status streams, webhooks and MQTT.
+package events
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("events")
+
+const (
+	EventTargetOnline  = "target.online"
+	EventTargetOffline = "target.offline"
+	EventActionWake    = "action.wake"
+	EventActionHalt    = "action.halt"
+	EventActionReboot  = "action.reboot"
+	EventActionExec    = "action.exec"
+	EventWakeVerified  = "wake.verified"
+	EventWakeTimeout   = "wake.timeout"
+)
+
+type Event struct {
+	Id     uint64      `json:"id"`
+	Type   string      `json:"type"`
+	Target string      `json:"target"`
+	Time   time.Time   `json:"time"`
+	Data   interface{} `json:"data,omitempty"`
+}
+
+type ActionEventData struct {
+	Error string `json:"error,omitempty"`
+}
+
+const eventHistorySize = 256
+
+// Bus fans out events to all subscribers. Slow subscribers lose events
+// instead of blocking the publisher. Recent events are kept in bounded
+// history, so that subscribers can resume after reconnect.
+type Bus struct {
+	mu          sync.Mutex
+	lastId      uint64
+	history     []Event
+	subscribers map[chan Event]struct{}
+}
+
+// DefaultBus is used by package level functions.
+var DefaultBus = NewBus()
+
+func NewBus() *Bus {
+	return &Bus{
+		history:     make([]Event, 0, eventHistorySize),
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+func (b *Bus) Publish(eventType string, target string, data interface{}) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastId++
+	event := Event{
+		Id:     b.lastId,
+		Type:   eventType,
+		Target: target,
+		Time:   time.Now(),
+		Data:   data,
+	}
+
+	if len(b.history) == eventHistorySize {
+		b.history = append(b.history[:0], b.history[1:]...)
+	}
+	b.history = append(b.history, event)
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			log.Warningf("Event subscriber is too slow, dropping event %d", event.Id)
+		}
+	}
+
+	return event
+}
+
+// Subscribe returns channel receiving all published events and function
+// which must be called to unsubscribe.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	_, ch, unsubscribe := b.SubscribeSince(math.MaxUint64)
+	return ch, unsubscribe
+}
+
+// SubscribeSince works as Subscribe, but additionally returns events from
+// history published after event with given id.
+func (b *Bus) SubscribeSince(lastId uint64) ([]Event, <-chan Event, func()) {
+	ch := make(chan Event, 64)
+
+	b.mu.Lock()
+	var missed []Event
+	for _, event := range b.history {
+		if event.Id > lastId {
+			missed = append(missed, event)
+		}
+	}
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return missed, ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func Publish(eventType string, target string, data interface{}) Event {
+	return DefaultBus.Publish(eventType, target, data)
+}
+
+func Subscribe() (<-chan Event, func()) {
+	return DefaultBus.Subscribe()
+}
+
+func SubscribeSince(lastId uint64) ([]Event, <-chan Event, func()) {
+	return DefaultBus.SubscribeSince(lastId)
+}
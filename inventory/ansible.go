@@ -0,0 +1,300 @@
+package inventory
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"homecontroller/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// hostRangePattern matches numeric host range of Ansible, e.g. web[01:03].
+var hostRangePattern = regexp.MustCompile(`^(.*)\[([0-9]+):([0-9]+)\](.*)$`)
+
+// ansibleInventory collects hosts with their variables in order in which
+// they first appear.
+type ansibleInventory struct {
+	names []string
+	vars  map[string]map[string]string
+}
+
+func newAnsibleInventory() *ansibleInventory {
+	return &ansibleInventory{vars: make(map[string]map[string]string)}
+}
+
+// add records host, variables of host seen before are kept.
+func (inv *ansibleInventory) add(pattern string, vars map[string]string) {
+	for _, name := range expandHostRange(pattern) {
+		existing, ok := inv.vars[name]
+		if !ok {
+			inv.names = append(inv.names, name)
+			inv.vars[name] = maps.Clone(vars)
+			continue
+		}
+
+		for key, value := range vars {
+			if _, ok := existing[key]; !ok {
+				existing[key] = value
+			}
+		}
+	}
+}
+
+func (inv *ansibleInventory) targets() ([]config.TargetConfiguration, error) {
+	var targets []config.TargetConfiguration
+	for _, name := range inv.names {
+		target, err := ansibleTarget(name, inv.vars[name])
+		if err != nil {
+			return nil, fmt.Errorf("host %s: %v", name, err)
+		}
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+func expandHostRange(pattern string) []string {
+	match := hostRangePattern.FindStringSubmatch(pattern)
+	if match == nil {
+		return []string{pattern}
+	}
+
+	from, _ := strconv.Atoi(match[2])
+	to, _ := strconv.Atoi(match[3])
+	var names []string
+	for i := from; i <= to; i++ {
+		names = append(names, fmt.Sprintf("%s%0*d%s", match[1], len(match[2]), i, match[4]))
+	}
+
+	return names
+}
+
+// ansibleTarget builds target from connection variables of host. MAC address
+// is taken from mac or mac_address variable.
+func ansibleTarget(name string, vars map[string]string) (config.TargetConfiguration, error) {
+	value := func(keys ...string) string {
+		for _, key := range keys {
+			// templates cannot be evaluated here
+			if v := vars[key]; v != "" && !strings.Contains(v, "{{") {
+				return v
+			}
+		}
+		return ""
+	}
+
+	target := config.TargetConfiguration{
+		Id:   name,
+		Host: value("ansible_host", "ansible_ssh_host"),
+	}
+	if target.Host == "" {
+		target.Host = name
+	}
+
+	target.Ssh.User = value("ansible_user", "ansible_ssh_user")
+	target.Ssh.PrivateKey.Path = value("ansible_ssh_private_key_file", "ansible_private_key_file")
+
+	if port := value("ansible_port", "ansible_ssh_port"); port != "" {
+		number, err := strconv.Atoi(port)
+		if err != nil {
+			return target, fmt.Errorf("invalid port '%s'", port)
+		}
+		target.Ssh.Port = &number
+	}
+
+	if mac := value("mac", "mac_address"); mac != "" {
+		hwAddress, err := normalizeMac(mac)
+		if err != nil {
+			return target, err
+		}
+		target.Mac = hwAddress
+	}
+
+	return target, nil
+}
+
+type ansibleYamlGroup struct {
+	Hosts    map[string]map[string]interface{} `yaml:"hosts"`
+	Vars     map[string]interface{}            `yaml:"vars"`
+	Children map[string]*ansibleYamlGroup      `yaml:"children"`
+}
+
+// ParseAnsibleYaml parses hosts of Ansible inventory in YAML format,
+// variables of groups are inherited by their hosts and children.
+func ParseAnsibleYaml(r io.Reader) ([]config.TargetConfiguration, error) {
+	var groups map[string]*ansibleYamlGroup
+	if err := yaml.NewDecoder(r).Decode(&groups); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	inventory := newAnsibleInventory()
+	for _, name := range slices.Sorted(maps.Keys(groups)) {
+		inventory.walkYamlGroup(groups[name], nil)
+	}
+
+	return inventory.targets()
+}
+
+func (inv *ansibleInventory) walkYamlGroup(group *ansibleYamlGroup, inherited map[string]string) {
+	if group == nil {
+		return
+	}
+
+	vars := maps.Clone(inherited)
+	if vars == nil {
+		vars = make(map[string]string)
+	}
+	for key, value := range group.Vars {
+		vars[key] = fmt.Sprint(value)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(group.Hosts)) {
+		hostVars := maps.Clone(vars)
+		for key, value := range group.Hosts[name] {
+			hostVars[key] = fmt.Sprint(value)
+		}
+		inv.add(name, hostVars)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(group.Children)) {
+		inv.walkYamlGroup(group.Children[name], vars)
+	}
+}
+
+// ParseAnsibleIni parses hosts of Ansible inventory in INI format. Variables
+// of [group:vars] sections are inherited by hosts of the group and of its
+// [group:children].
+func ParseAnsibleIni(r io.Reader) ([]config.TargetConfiguration, error) {
+	var hostOrder []string
+	hostVars := make(map[string]map[string]string)
+	hostGroups := make(map[string][]string)
+	groupVars := make(map[string]map[string]string)
+	parents := make(map[string][]string)
+
+	section, kind := "ungrouped", ""
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section, kind, _ = strings.Cut(line[1:len(line)-1], ":")
+			continue
+		}
+
+		switch kind {
+		case "vars":
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected key=value", lineNumber)
+			}
+			if groupVars[section] == nil {
+				groupVars[section] = make(map[string]string)
+			}
+			groupVars[section][strings.TrimSpace(key)] = unquote(strings.TrimSpace(value))
+		case "children":
+			parents[line] = append(parents[line], section)
+		case "":
+			fields := splitFields(line)
+			name := fields[0]
+			if _, ok := hostVars[name]; !ok {
+				hostOrder = append(hostOrder, name)
+				hostVars[name] = make(map[string]string)
+			}
+			for _, field := range fields[1:] {
+				key, value, ok := strings.Cut(field, "=")
+				if !ok {
+					return nil, fmt.Errorf("line %d: expected key=value, got '%s'", lineNumber, field)
+				}
+				hostVars[name][key] = unquote(value)
+			}
+			hostGroups[name] = append(hostGroups[name], section)
+		default:
+			return nil, fmt.Errorf("line %d: unknown section type '%s'", lineNumber, kind)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// vars of group, parent groups and all, the closer group wins
+	var resolveGroupVars func(group string, visited map[string]bool) map[string]string
+	resolveGroupVars = func(group string, visited map[string]bool) map[string]string {
+		vars := make(map[string]string)
+		if visited[group] {
+			return vars
+		}
+		visited[group] = true
+
+		if group != "all" {
+			maps.Copy(vars, groupVars["all"])
+		}
+		for _, parent := range parents[group] {
+			maps.Copy(vars, resolveGroupVars(parent, visited))
+		}
+		maps.Copy(vars, groupVars[group])
+		return vars
+	}
+
+	inventory := newAnsibleInventory()
+	for _, name := range hostOrder {
+		vars := make(map[string]string)
+		for _, group := range hostGroups[name] {
+			maps.Copy(vars, resolveGroupVars(group, make(map[string]bool)))
+		}
+		maps.Copy(vars, hostVars[name])
+		inventory.add(name, vars)
+	}
+
+	return inventory.targets()
+}
+
+// splitFields splits line of hosts section by whitespace outside of quotes,
+// e.g. ansible_ssh_common_args='-o ProxyCommand=...'.
+func splitFields(line string) []string {
+	var fields []string
+	var field strings.Builder
+	var quote rune
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			field.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			field.WriteRune(r)
+		case r == ' ' || r == '\t':
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteRune(r)
+		}
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+
+	return fields
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+
+	return value
+}
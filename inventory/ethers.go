@@ -0,0 +1,39 @@
+package inventory
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"homecontroller/config"
+)
+
+// ParseEthers parses /etc/ethers, lines of MAC address and host name or IP
+// address. Both id and host of target are the name.
+func ParseEthers(r io.Reader) ([]config.TargetConfiguration, error) {
+	var targets []config.TargetConfiguration
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected MAC address and host", lineNumber)
+		}
+
+		mac, err := normalizeMac(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+
+		targets = append(targets, config.TargetConfiguration{Id: fields[1], Host: fields[1], Mac: mac})
+	}
+
+	return targets, scanner.Err()
+}
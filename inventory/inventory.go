@@ -0,0 +1,182 @@
+// Package inventory builds target configuration from inventories of other
+// tools: Ansible inventories, /etc/ethers and ssh_config.
+package inventory
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"homecontroller/config"
+	"homecontroller/sshctl"
+)
+
+type Format string
+
+const (
+	FormatAnsible   Format = "ansible"
+	FormatEthers    Format = "ethers"
+	FormatSshConfig Format = "ssh_config"
+)
+
+// Source is inventory file of given format.
+type Source struct {
+	Format Format
+	Path   string
+}
+
+// ParseSource parses source given as FORMAT:PATH. Path of ethers and
+// ssh_config may be left out, /etc/ethers and ~/.ssh/config are used then.
+func ParseSource(value string) (Source, error) {
+	format, path, _ := strings.Cut(value, ":")
+	source := Source{Format: Format(format), Path: path}
+
+	switch source.Format {
+	case FormatAnsible:
+		if path == "" {
+			return source, fmt.Errorf("path of ansible inventory is required, e.g. ansible:hosts.ini")
+		}
+	case FormatEthers:
+		if path == "" {
+			source.Path = "/etc/ethers"
+		}
+	case FormatSshConfig:
+		if path == "" {
+			source.Path = "~/.ssh/config"
+		}
+	default:
+		return source, fmt.Errorf("unknown inventory format '%s', use ansible, ethers or ssh_config", format)
+	}
+
+	source.Path = sshctl.ExpandHomePath(source.Path)
+	return source, nil
+}
+
+// Creates reports whether source adds new targets. Other sources only fill
+// fields of targets which are configured or added by other source.
+func (s Source) Creates() bool {
+	return s.Format == FormatAnsible
+}
+
+func (s Source) String() string {
+	return fmt.Sprintf("%s:%s", s.Format, s.Path)
+}
+
+// Read parses targets from source file.
+func (s Source) Read() ([]config.TargetConfiguration, error) {
+	file, err := os.Open(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("couldnt open %s, %v", s.Path, err)
+	}
+	defer file.Close()
+
+	var targets []config.TargetConfiguration
+	switch s.Format {
+	case FormatAnsible:
+		switch strings.ToLower(filepath.Ext(s.Path)) {
+		case ".yml", ".yaml", ".json":
+			targets, err = ParseAnsibleYaml(file)
+		default:
+			targets, err = ParseAnsibleIni(file)
+		}
+	case FormatEthers:
+		targets, err = ParseEthers(file)
+	case FormatSshConfig:
+		targets, err = ParseSshConfig(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldnt parse %s, %v", s.Path, err)
+	}
+
+	return targets, nil
+}
+
+// Merge fills fields missing in targets from entries with the same id or
+// host and appends entries which match no target.
+func Merge(targets []config.TargetConfiguration, entries []config.TargetConfiguration) []config.TargetConfiguration {
+	for _, entry := range entries {
+		matched := false
+		for i := range targets {
+			if matches(&targets[i], &entry) {
+				fill(&targets[i], &entry)
+				matched = true
+			}
+		}
+
+		if !matched {
+			targets = append(targets, entry)
+		}
+	}
+
+	return targets
+}
+
+// Enrich fills fields missing in targets from entries with the same id or
+// host. Entries which match no target are ignored.
+func Enrich(targets []config.TargetConfiguration, entries []config.TargetConfiguration) {
+	for i := range targets {
+		for _, entry := range entries {
+			if matches(&targets[i], &entry) {
+				fill(&targets[i], &entry)
+			}
+		}
+	}
+}
+
+func matches(target *config.TargetConfiguration, entry *config.TargetConfiguration) bool {
+	return strings.EqualFold(target.Id, entry.Id) ||
+		sameHost(target.Host, entry.Id) ||
+		sameHost(target.Host, entry.Host)
+}
+
+// sameHost compares host names, name without domain matches name with
+// domain, e.g. nas and nas.lan.
+func sameHost(a string, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	if strings.EqualFold(a, b) {
+		return true
+	}
+	if net.ParseIP(a) != nil || net.ParseIP(b) != nil {
+		return false
+	}
+
+	shortA, _, _ := strings.Cut(a, ".")
+	shortB, _, _ := strings.Cut(b, ".")
+	return (shortA == a || shortB == b) && strings.EqualFold(shortA, shortB)
+}
+
+func fill(target *config.TargetConfiguration, entry *config.TargetConfiguration) {
+	if target.Host == "" {
+		target.Host = entry.Host
+	}
+	if target.Mac == "" {
+		target.Mac = entry.Mac
+	}
+	if target.Ssh.User == "" {
+		target.Ssh.User = entry.Ssh.User
+	}
+	if target.Ssh.Port == nil {
+		target.Ssh.Port = entry.Ssh.Port
+	}
+	if target.Ssh.PrivateKey.Path == "" {
+		target.Ssh.PrivateKey.Path = entry.Ssh.PrivateKey.Path
+	}
+	if len(target.Ssh.ProxyJump) == 0 {
+		target.Ssh.ProxyJump = entry.Ssh.ProxyJump
+	}
+}
+
+// normalizeMac returns MAC address in form used in config, lower case with
+// colons.
+func normalizeMac(value string) (config.HwAddress, error) {
+	mac, err := net.ParseMAC(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid mac '%s'", value)
+	}
+
+	return config.HwAddress(mac.String()), nil
+}
@@ -0,0 +1,63 @@
+package inventory
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"homecontroller/config"
+	"homecontroller/sshctl"
+
+	"github.com/kevinburke/ssh_config"
+)
+
+// ParseSshConfig parses Host entries of ssh_config, entries with wildcards
+// only provide defaults for the others. Id of target is the alias, host is
+// its HostName.
+func ParseSshConfig(r io.Reader) ([]config.TargetConfiguration, error) {
+	sshConfig, err := ssh_config.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []config.TargetConfiguration
+	seen := make(map[string]bool)
+	for _, host := range sshConfig.Hosts {
+		for _, pattern := range host.Patterns {
+			alias := pattern.String()
+			if strings.ContainsAny(alias, "*?!") || seen[alias] {
+				continue
+			}
+			seen[alias] = true
+
+			get := func(key string) string {
+				value, _ := sshConfig.Get(alias, key)
+				return value
+			}
+
+			target := config.TargetConfiguration{Id: alias, Host: alias}
+			// tokens like %h are expanded only by ssh
+			if hostName := get("HostName"); hostName != "" && !strings.Contains(hostName, "%") {
+				target.Host = hostName
+			}
+
+			target.Ssh.User = get("User")
+			target.Ssh.PrivateKey.Path = get("IdentityFile")
+			if port, err := strconv.Atoi(get("Port")); err == nil {
+				target.Ssh.Port = &port
+			}
+
+			for _, jump := range sshctl.ParseProxyJump(get("ProxyJump")) {
+				target.Ssh.ProxyJump = append(target.Ssh.ProxyJump, config.SshJumpConfiguration{
+					Host: jump.Host,
+					User: jump.User,
+					Port: jump.Port,
+				})
+			}
+
+			targets = append(targets, target)
+		}
+	}
+
+	return targets, nil
+}
@@ -0,0 +1,173 @@
+// Package metrics exposes Prometheus metrics of actions, HTTP requests and
+// observed target status. Target label is lower-cased host of the machine,
+// or its MAC address when host is not known, so that actions and probes of
+// one machine share it.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "homecontroller"
+
+var (
+	metricActionAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "action_attempts_total",
+		Help:      "Number of wake/halt attempts by target.",
+	}, []string{"action", "target"})
+
+	metricActionFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "action_failures_total",
+		Help:      "Number of failed wake/halt attempts by target and reason.",
+	}, []string{"action", "target", "reason"})
+
+	metricHttpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "Number of handled HTTP requests by route and status code.",
+	}, []string{"route", "code"})
+
+	metricHttpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of handled HTTP requests by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route"})
+
+	metricStreamClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "status_stream_clients",
+		Help:      "Number of connected status-stream websocket clients.",
+	})
+
+	targetStatus = newTargetStatusCollector()
+)
+
+func init() {
+	prometheus.MustRegister(
+		metricActionAttempts,
+		metricActionFailures,
+		metricHttpRequests,
+		metricHttpDuration,
+		metricStreamClients,
+		targetStatus,
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+type targetStatusEntry struct {
+	isOnline  bool
+	rtt       time.Duration
+	changedAt time.Time
+}
+
+// targetStatusCollector keeps the last observed state of every probed target,
+// so the time since the last state change can be computed at scrape time.
+type targetStatusCollector struct {
+	mu      sync.Mutex
+	targets map[string]*targetStatusEntry
+
+	onlineDesc *prometheus.Desc
+	rttDesc    *prometheus.Desc
+	sinceDesc  *prometheus.Desc
+}
+
+func newTargetStatusCollector() *targetStatusCollector {
+	return &targetStatusCollector{
+		targets: make(map[string]*targetStatusEntry),
+		onlineDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "target", "online"),
+			"Whether the target responded to the last probe (1) or not (0).",
+			[]string{"target"}, nil,
+		),
+		rttDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "target", "rtt_seconds"),
+			"Round trip time of the last successful probe.",
+			[]string{"target"}, nil,
+		),
+		sinceDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "target", "seconds_since_state_change"),
+			"Seconds elapsed since the target switched between online and offline.",
+			[]string{"target"}, nil,
+		),
+	}
+}
+
+func (c *targetStatusCollector) Observe(target string, isOnline bool, rtt time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.targets[target]
+	if !ok {
+		entry = &targetStatusEntry{isOnline: isOnline, changedAt: time.Now()}
+		c.targets[target] = entry
+	} else if entry.isOnline != isOnline {
+		entry.isOnline = isOnline
+		entry.changedAt = time.Now()
+	}
+
+	if isOnline && rtt > 0 {
+		entry.rtt = rtt
+	}
+}
+
+func (c *targetStatusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.onlineDesc
+	ch <- c.rttDesc
+	ch <- c.sinceDesc
+}
+
+func (c *targetStatusCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for target, entry := range c.targets {
+		online := 0.0
+		if entry.isOnline {
+			online = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.onlineDesc, prometheus.GaugeValue, online, target)
+		ch <- prometheus.MustNewConstMetric(c.rttDesc, prometheus.GaugeValue, entry.rtt.Seconds(), target)
+		ch <- prometheus.MustNewConstMetric(c.sinceDesc, prometheus.GaugeValue, time.Since(entry.changedAt).Seconds(), target)
+	}
+}
+
+// ObserveTargetStatus records result of probe of target, rtt is kept only
+// for successful probes.
+func ObserveTargetStatus(target string, isOnline bool, rtt time.Duration) {
+	targetStatus.Observe(target, isOnline, rtt)
+}
+
+// ObserveAction records attempt of action, failed attempt is counted under
+// given reason.
+func ObserveAction(action string, target string, failed bool, reason string) {
+	metricActionAttempts.WithLabelValues(action, target).Inc()
+	if failed {
+		metricActionFailures.WithLabelValues(action, target, reason).Inc()
+	}
+}
+
+func ObserveHttpRequest(route string, statusCode int, took time.Duration) {
+	metricHttpRequests.WithLabelValues(route, strconv.Itoa(statusCode)).Inc()
+	metricHttpDuration.WithLabelValues(route).Observe(took.Seconds())
+}
+
+func StreamClientConnected() {
+	metricStreamClients.Inc()
+}
+
+func StreamClientDisconnected() {
+	metricStreamClients.Dec()
+}
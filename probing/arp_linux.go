@@ -0,0 +1,187 @@
+package probing
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const arpCompleteFlag = 0x2
+
+type ArpEntry struct {
+	Ip       string
+	Mac      string
+	Device   string
+	Complete bool
+}
+
+func ReadArpTable() ([]ArpEntry, error) {
+	file, err := os.Open("/proc/net/arp")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []ArpEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // skip header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+
+		flags, err := strconv.ParseUint(strings.TrimPrefix(fields[2], "0x"), 16, 32)
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, ArpEntry{
+			Ip:       fields[0],
+			Mac:      fields[3],
+			Device:   fields[5],
+			Complete: flags&arpCompleteFlag != 0,
+		})
+	}
+
+	return entries, scanner.Err()
+}
+
+func LookupArpEntry(ip net.IP) (*ArpEntry, error) {
+	entries, err := ReadArpTable()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		if entries[i].Complete && net.ParseIP(entries[i].Ip).Equal(ip) {
+			return &entries[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+const (
+	// arpResolveTimeout is how long host without neighbour entry has to
+	// answer ARP request
+	arpResolveTimeout = time.Second
+	// arpVerifyTimeout covers kernel verifying stale entry, DELAY state
+	// (5s by default) followed by unicast probes
+	arpVerifyTimeout = 8 * time.Second
+)
+
+// probeArp makes kernel resolve host on local network by sending empty UDP
+// datagram and reports it online once its neighbour entry is REACHABLE, i.e.
+// host answered recently. STALE entries are kept long after host goes down,
+// so complete entry itself is not trusted.
+func probeArp(host string) (bool, error) {
+	ip, err := ResolveIPv4(host)
+	if err != nil {
+		return false, err
+	}
+
+	state, err := neighbourState(ip)
+	if err != nil {
+		return false, err
+	}
+	if state&unix.NUD_REACHABLE != 0 {
+		return true, nil
+	}
+
+	conn, err := net.Dial("udp4", net.JoinHostPort(ip.String(), "9"))
+	if err != nil {
+		return false, err
+	}
+	conn.Write([]byte{0})
+	conn.Close()
+
+	start := time.Now()
+	deadline := start.Add(arpResolveTimeout)
+	for {
+		state, err := neighbourState(ip)
+		if err != nil {
+			return false, err
+		}
+
+		switch {
+		case state&unix.NUD_REACHABLE != 0:
+			return true, nil
+		case state&unix.NUD_FAILED != 0:
+			return false, nil
+		case state&(unix.NUD_DELAY|unix.NUD_PROBE) != 0:
+			// kernel verifies stale entry, wait for its outcome
+			deadline = start.Add(arpVerifyTimeout)
+		}
+
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// neighbourState returns NUD state of ip in kernel neighbour table read via
+// netlink, NUD_NONE when there is no entry.
+func neighbourState(ip net.IP) (uint16, error) {
+	rib, err := syscall.NetlinkRIB(syscall.RTM_GETNEIGH, syscall.AF_INET)
+	if err != nil {
+		return 0, fmt.Errorf("couldnt read neighbour table, %v", err)
+	}
+
+	messages, err := syscall.ParseNetlinkMessage(rib)
+	if err != nil {
+		return 0, fmt.Errorf("couldnt parse neighbour table, %v", err)
+	}
+
+	var state uint16
+	for _, message := range messages {
+		if message.Header.Type != syscall.RTM_NEWNEIGH {
+			continue
+		}
+		// the same ip may be on multiple interfaces, any reachable is enough
+		if entryState, ok := parseNeighbourState(message.Data, ip); ok && (state == unix.NUD_NONE || entryState&unix.NUD_REACHABLE != 0) {
+			state = entryState
+		}
+	}
+
+	return state, nil
+}
+
+// parseNeighbourState returns state of neighbour message (ndmsg followed by
+// attributes) when its destination is ip.
+func parseNeighbourState(data []byte, ip net.IP) (uint16, bool) {
+	if len(data) < unix.SizeofNdMsg {
+		return 0, false
+	}
+	// ndmsg: family, pads, ifindex, then state at offset 8
+	state := binary.NativeEndian.Uint16(data[8:10])
+
+	attrs := data[unix.SizeofNdMsg:]
+	for len(attrs) >= unix.SizeofRtAttr {
+		length := int(binary.NativeEndian.Uint16(attrs[0:2]))
+		if length < unix.SizeofRtAttr || length > len(attrs) {
+			break
+		}
+
+		if binary.NativeEndian.Uint16(attrs[2:4]) == unix.NDA_DST {
+			return state, net.IP(attrs[unix.SizeofRtAttr:length]).Equal(ip)
+		}
+
+		aligned := (length + unix.RTA_ALIGNTO - 1) &^ (unix.RTA_ALIGNTO - 1)
+		if aligned >= len(attrs) {
+			break
+		}
+		attrs = attrs[aligned:]
+	}
+
+	return 0, false
+}
@@ -0,0 +1,51 @@
+package probing
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func neighbourMessage(state uint16, ip net.IP) []byte {
+	data := make([]byte, unix.SizeofNdMsg)
+	data[0] = unix.AF_INET
+	binary.NativeEndian.PutUint16(data[8:10], state)
+
+	// lladdr attribute before destination, padded to 4 bytes
+	lladdr := make([]byte, 12)
+	binary.NativeEndian.PutUint16(lladdr[0:2], 10)
+	binary.NativeEndian.PutUint16(lladdr[2:4], unix.NDA_LLADDR)
+	data = append(data, lladdr...)
+
+	dst := make([]byte, unix.SizeofRtAttr)
+	binary.NativeEndian.PutUint16(dst[0:2], uint16(unix.SizeofRtAttr+len(ip)))
+	binary.NativeEndian.PutUint16(dst[2:4], unix.NDA_DST)
+	return append(append(data, dst...), ip...)
+}
+
+func TestParseNeighbourState(t *testing.T) {
+	ip := net.ParseIP("192.168.1.10").To4()
+
+	tests := []struct {
+		name  string
+		data  []byte
+		state uint16
+		found bool
+	}{
+		{"reachable", neighbourMessage(unix.NUD_REACHABLE, ip), unix.NUD_REACHABLE, true},
+		{"stale", neighbourMessage(unix.NUD_STALE, ip), unix.NUD_STALE, true},
+		{"other ip", neighbourMessage(unix.NUD_REACHABLE, net.ParseIP("192.168.1.11").To4()), 0, false},
+		{"truncated", []byte{unix.AF_INET, 0, 0}, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state, found := parseNeighbourState(test.data, ip)
+			if found != test.found || (found && state != test.state) {
+				t.Errorf("got state %#x found %v, want %#x %v", state, found, test.state, test.found)
+			}
+		})
+	}
+}
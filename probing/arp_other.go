@@ -0,0 +1,29 @@
+//go:build !linux
+
+package probing
+
+import (
+	"errors"
+	"net"
+)
+
+type ArpEntry struct {
+	Ip       string
+	Mac      string
+	Device   string
+	Complete bool
+}
+
+var errArpUnsupported = errors.New("neighbour table is only supported on linux")
+
+func ReadArpTable() ([]ArpEntry, error) {
+	return nil, errArpUnsupported
+}
+
+func LookupArpEntry(ip net.IP) (*ArpEntry, error) {
+	return nil, errArpUnsupported
+}
+
+func probeArp(host string) (bool, error) {
+	return false, errArpUnsupported
+}
@@ -0,0 +1,159 @@
+package probing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	statusCacheMaxAge    = 2 * time.Second
+	observeRetryInterval = 5 * time.Second
+)
+
+// DefaultHub is shared by all status observers of the process.
+var DefaultHub = NewHub()
+
+type cachedStatus struct {
+	status     Status
+	observedAt time.Time
+}
+
+// Hub keeps single probe loop per observed host and probe
+// configuration and fans out its updates to all subscribers. Probing stops
+// when last subscriber leaves.
+type Hub struct {
+	mu        sync.Mutex
+	observers map[string]*hostObserver
+	cache     map[string]cachedStatus
+}
+
+type hostObserver struct {
+	cancel      context.CancelFunc
+	subscribers map[chan Status]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		observers: make(map[string]*hostObserver),
+		cache:     make(map[string]cachedStatus),
+	}
+}
+
+// Subscribe returns channel receiving status updates of host and function
+// which must be called to unsubscribe, which closes the channel. Channel
+// always holds only the most recent status, so slow subscribers don't block
+// others.
+func (hub *Hub) Subscribe(host string, probe Config) (<-chan Status, func()) {
+	ch := make(chan Status, 1)
+	key := probe.Key(host)
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	observer, ok := hub.observers[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		observer = &hostObserver{
+			cancel:      cancel,
+			subscribers: make(map[chan Status]struct{}),
+		}
+		hub.observers[key] = observer
+		go hub.observe(ctx, host, probe, observer)
+	}
+	observer.subscribers[ch] = struct{}{}
+
+	if cached, ok := hub.cache[key]; ok && time.Since(cached.observedAt) < statusCacheMaxAge {
+		ch <- cached.status
+	}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			hub.unsubscribe(key, observer, ch)
+		})
+	}
+}
+
+func (hub *Hub) unsubscribe(key string, observer *hostObserver, ch chan Status) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	delete(observer.subscribers, ch)
+	close(ch)
+	if len(observer.subscribers) == 0 {
+		observer.cancel()
+		delete(hub.observers, key)
+	}
+}
+
+func (hub *Hub) observe(ctx context.Context, host string, probe Config, observer *hostObserver) {
+	key := probe.Key(host)
+	updates := make(chan Status)
+	go func() {
+		for status := range updates {
+			hub.publish(key, observer, status)
+		}
+	}()
+
+	defer close(updates)
+
+	for {
+		err := Observe(ctx, host, probe, updates)
+		if err == nil {
+			return
+		}
+
+		log.Errorf("Could not observe host %s: %v", host, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(observeRetryInterval):
+		}
+	}
+}
+
+func (hub *Hub) publish(key string, observer *hostObserver, status Status) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	hub.store(key, status)
+	for ch := range observer.subscribers {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- status
+	}
+}
+
+func (hub *Hub) store(key string, status Status) {
+	hub.cache[key] = cachedStatus{status, time.Now()}
+}
+
+// Status returns status of host, from cache when it is fresh enough,
+// otherwise by probing the host.
+func (hub *Hub) Status(ctx context.Context, host string, probe Config) (Status, error) {
+	key := probe.Key(host)
+
+	hub.mu.Lock()
+	cached, ok := hub.cache[key]
+	hub.mu.Unlock()
+
+	if ok && time.Since(cached.observedAt) < statusCacheMaxAge {
+		return cached.status, nil
+	}
+
+	isOnline, err := Online(ctx, host, probe)
+	if err != nil {
+		return Status{}, err
+	}
+
+	status := Status{IsOnline: isOnline}
+
+	hub.mu.Lock()
+	hub.store(key, status)
+	hub.mu.Unlock()
+
+	return status, nil
+}
@@ -0,0 +1,259 @@
+package probing
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeStatusPinger struct {
+	host string
+	stop chan struct{}
+
+	mu        sync.Mutex
+	onReceive func(rtt time.Duration)
+	stopOnce  sync.Once
+}
+
+func (p *fakeStatusPinger) OnReceive(fn func(rtt time.Duration)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.onReceive = fn
+}
+
+func (p *fakeStatusPinger) Run() error {
+	<-p.stop
+	return nil
+}
+
+func (p *fakeStatusPinger) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+}
+
+// reply simulates reply of host to the pinger.
+func (p *fakeStatusPinger) reply() {
+	p.mu.Lock()
+	fn := p.onReceive
+	p.mu.Unlock()
+
+	fn(time.Millisecond)
+}
+
+func (p *fakeStatusPinger) stopped() bool {
+	select {
+	case <-p.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+type fakePingers struct {
+	mu      sync.Mutex
+	pingers []*fakeStatusPinger
+	created chan *fakeStatusPinger
+}
+
+// useFakePingers replaces newStatusPinger by fake for the test.
+func useFakePingers(t *testing.T) *fakePingers {
+	fakes := &fakePingers{created: make(chan *fakeStatusPinger, 16)}
+
+	original := newStatusPinger
+	newStatusPinger = func(host string, probe Config) (statusPinger, error) {
+		pinger := &fakeStatusPinger{host: host, stop: make(chan struct{})}
+
+		fakes.mu.Lock()
+		fakes.pingers = append(fakes.pingers, pinger)
+		fakes.mu.Unlock()
+
+		fakes.created <- pinger
+		return pinger, nil
+	}
+	t.Cleanup(func() {
+		newStatusPinger = original
+	})
+
+	return fakes
+}
+
+func (f *fakePingers) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.pingers)
+}
+
+func (f *fakePingers) waitCreated(t *testing.T) *fakeStatusPinger {
+	t.Helper()
+
+	select {
+	case pinger := <-f.created:
+		return pinger
+	case <-time.After(time.Second):
+		t.Fatal("pinger was not created")
+		return nil
+	}
+}
+
+func waitStatus(t *testing.T, ch <-chan Status, isOnline bool) {
+	t.Helper()
+
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case status, ok := <-ch:
+			if !ok {
+				t.Fatal("subscription was closed")
+			}
+			if status.IsOnline == isOnline {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("status did not change to online %v", isOnline)
+		}
+	}
+}
+
+func waitStopped(t *testing.T, pinger *fakeStatusPinger) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !pinger.stopped() {
+		if time.Now().After(deadline) {
+			t.Fatalf("pinger of %s was not stopped", pinger.host)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func observerCount(hub *Hub) int {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	return len(hub.observers)
+}
+
+var testProbe = Config{Mode: ModeTcp}
+
+func TestHubSingleObserverPerKey(t *testing.T) {
+	fakes := useFakePingers(t)
+	hub := NewHub()
+
+	_, unsubscribeA := hub.Subscribe("pc.lan", testProbe)
+	defer unsubscribeA()
+	_, unsubscribeB := hub.Subscribe("pc.lan", testProbe)
+	defer unsubscribeB()
+	fakes.waitCreated(t)
+
+	_, unsubscribeOther := hub.Subscribe("nas.lan", testProbe)
+	defer unsubscribeOther()
+	fakes.waitCreated(t)
+
+	_, unsubscribePorts := hub.Subscribe("pc.lan", Config{Mode: ModeTcp, Ports: []int{22}})
+	defer unsubscribePorts()
+	fakes.waitCreated(t)
+
+	if count := observerCount(hub); count != 3 {
+		t.Errorf("hub has %d observers, expected 3", count)
+	}
+	if count := fakes.count(); count != 3 {
+		t.Errorf("%d pingers were created, expected 3", count)
+	}
+}
+
+func TestHubUnsubscribe(t *testing.T) {
+	fakes := useFakePingers(t)
+	hub := NewHub()
+
+	chA, unsubscribeA := hub.Subscribe("pc.lan", testProbe)
+	chB, unsubscribeB := hub.Subscribe("pc.lan", testProbe)
+	pinger := fakes.waitCreated(t)
+
+	unsubscribeA()
+	// repeated unsubscribe must not remove other subscriber
+	unsubscribeA()
+	if _, ok := <-chA; ok {
+		t.Error("channel of unsubscribed subscriber was not closed")
+	}
+
+	pinger.reply()
+	waitStatus(t, chB, true)
+	if pinger.stopped() {
+		t.Fatal("pinger was stopped while host has subscriber")
+	}
+
+	unsubscribeB()
+	waitStopped(t, pinger)
+	if count := observerCount(hub); count != 0 {
+		t.Errorf("hub has %d observers after last unsubscribe, expected 0", count)
+	}
+
+	// new subscriber starts new probe loop
+	_, unsubscribeC := hub.Subscribe("pc.lan", testProbe)
+	defer unsubscribeC()
+	if restarted := fakes.waitCreated(t); restarted == pinger {
+		t.Error("stopped pinger was reused")
+	}
+}
+
+func TestHubFanOut(t *testing.T) {
+	fakes := useFakePingers(t)
+	hub := NewHub()
+
+	chA, unsubscribeA := hub.Subscribe("pc.lan", testProbe)
+	defer unsubscribeA()
+	chB, unsubscribeB := hub.Subscribe("pc.lan", testProbe)
+	defer unsubscribeB()
+	pinger := fakes.waitCreated(t)
+
+	waitStatus(t, chA, false)
+	waitStatus(t, chB, false)
+
+	pinger.reply()
+	waitStatus(t, chA, true)
+	waitStatus(t, chB, true)
+}
+
+func TestHubStatusCache(t *testing.T) {
+	fakes := useFakePingers(t)
+	hub := NewHub()
+
+	ch, unsubscribe := hub.Subscribe("pc.lan", testProbe)
+	defer unsubscribe()
+	pinger := fakes.waitCreated(t)
+
+	pinger.reply()
+	waitStatus(t, ch, true)
+
+	// fresh status is served from cache, host is not probed
+	status, err := hub.Status(context.Background(), "pc.lan", testProbe)
+	if err != nil {
+		t.Fatalf("status of observed host failed: %v", err)
+	}
+	if !status.IsOnline {
+		t.Error("cached status is offline, expected online")
+	}
+
+	// late subscriber receives cached status right away
+	late, unsubscribeLate := hub.Subscribe("pc.lan", testProbe)
+	defer unsubscribeLate()
+	select {
+	case status := <-late:
+		if !status.IsOnline {
+			t.Error("late subscriber received offline, expected online")
+		}
+	default:
+		t.Error("late subscriber did not receive cached status")
+	}
+
+	hub.mu.Lock()
+	cached, ok := hub.cache[testProbe.Key("pc.lan")]
+	hub.mu.Unlock()
+	if !ok || !cached.status.IsOnline {
+		t.Error("status of observed host was not cached")
+	}
+}
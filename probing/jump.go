@@ -0,0 +1,125 @@
+package probing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"homecontroller/sshctl"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var errProbeWithoutJump = errors.New("probe mode jump requires ssh proxy_jump")
+
+func probeViaJumpOnce(ctx context.Context, host string, probe Config) (bool, time.Duration, error) {
+	if len(probe.Jumps) == 0 {
+		return false, 0, errProbeWithoutJump
+	}
+
+	client, release, err := sshctl.DefaultPool.Acquire(ctx, probe.Jumps, nil)
+	if err != nil {
+		return false, 0, err
+	}
+	defer release()
+
+	isOnline, rtt := probeTcpVia(client, host, probe.ports())
+	return isOnline, rtt, nil
+}
+
+// probeTcpVia connects to ports of host from SSH server of client. Unlike
+// direct TCP probe, refused connection cannot be told apart from unreachable
+// host, so only accepted connection means online.
+func probeTcpVia(client *ssh.Client, host string, ports []int) (bool, time.Duration) {
+	type result struct {
+		isOnline bool
+		rtt      time.Duration
+	}
+
+	results := make(chan result, len(ports))
+	for _, port := range ports {
+		go func() {
+			start := time.Now()
+			dialed := make(chan bool, 1)
+			go func() {
+				conn, err := client.Dial("tcp", net.JoinHostPort(host, fmt.Sprint(port)))
+				if err == nil {
+					conn.Close()
+				}
+				dialed <- err == nil
+			}()
+
+			// jump host may take long to give up on unreachable host
+			select {
+			case isOnline := <-dialed:
+				results <- result{isOnline, time.Since(start)}
+			case <-time.After(probeTimeout):
+				results <- result{false, 0}
+			}
+		}()
+	}
+
+	for range ports {
+		if r := <-results; r.isOnline {
+			return true, r.rtt
+		}
+	}
+
+	return false, 0
+}
+
+// jumpStatusPinger implements statusPinger by repeating TCP probes from jump
+// host, pooled SSH connection is held between probes.
+type jumpStatusPinger struct {
+	host  string
+	probe Config
+
+	onReceive func(rtt time.Duration)
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+func (p *jumpStatusPinger) OnReceive(fn func(rtt time.Duration)) {
+	p.onReceive = fn
+}
+
+func (p *jumpStatusPinger) Run() error {
+	client, release, err := sshctl.DefaultPool.Acquire(context.Background(), p.probe.Jumps, nil)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	closed := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(probeInterval)
+	defer ticker.Stop()
+
+	for {
+		isOnline, rtt := probeTcpVia(client, p.host, p.probe.ports())
+		if isOnline && p.onReceive != nil {
+			p.onReceive(rtt)
+		}
+
+		select {
+		case <-p.stop:
+			return nil
+		case <-closed:
+			return errors.New("connection to jump host closed")
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *jumpStatusPinger) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+}
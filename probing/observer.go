@@ -0,0 +1,131 @@
+package probing
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-ping/ping"
+)
+
+// Status of observed host.
+type Status struct {
+	IsOnline bool `json:"is_online"`
+}
+
+const (
+	observeTickInterval  = 500 * time.Millisecond
+	observeOnlineTimeout = 2 * time.Second
+)
+
+// statusPinger is continuously probing pinger used by Observe.
+type statusPinger interface {
+	// OnReceive sets callback called on every received reply, possibly from
+	// other goroutine.
+	OnReceive(func(rtt time.Duration))
+	// Run blocks until Stop is called or pinging fails.
+	Run() error
+	Stop()
+}
+
+type icmpStatusPinger struct {
+	*ping.Pinger
+}
+
+func (p icmpStatusPinger) OnReceive(fn func(rtt time.Duration)) {
+	p.Pinger.OnRecv = func(packet *ping.Packet) {
+		fn(packet.Rtt)
+	}
+}
+
+// newStatusPinger creates pinger for host, replaceable by fake in tests.
+var newStatusPinger = func(host string, probe Config) (statusPinger, error) {
+	if err := probe.Mode.Validate(); err != nil {
+		return nil, err
+	}
+
+	mode := probe.ResolvedMode()
+	if mode == ModeJump {
+		if len(probe.Jumps) == 0 {
+			return nil, errProbeWithoutJump
+		}
+
+		return &jumpStatusPinger{
+			host:  host,
+			probe: probe,
+			stop:  make(chan struct{}),
+		}, nil
+	}
+
+	if !mode.isIcmp() {
+		return &tcpStatusPinger{
+			host:  host,
+			mode:  mode,
+			ports: probe.ports(),
+			stop:  make(chan struct{}),
+		}, nil
+	}
+
+	pinger, err := newIcmpPinger(host, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	pinger.RecordRtts = false
+	return icmpStatusPinger{pinger}, nil
+}
+
+// Observe continuously pings host and sends its status to updates
+// every tick. It blocks until ctx is cancelled, in which case it returns nil,
+// or until pinging fails.
+func Observe(ctx context.Context, host string, probe Config, updates chan<- Status) error {
+	pinger, err := newStatusPinger(host, probe)
+	if err != nil {
+		return err
+	}
+
+	received := make(chan time.Duration, 1)
+	pinger.OnReceive(func(rtt time.Duration) {
+		select {
+		case received <- rtt:
+		default:
+		}
+	})
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- pinger.Run()
+	}()
+
+	defer func() {
+		pinger.Stop()
+		<-runErr
+	}()
+
+	ticker := time.NewTicker(observeTickInterval)
+	defer ticker.Stop()
+
+	var lastReceived time.Time
+	var lastRtt time.Duration
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-runErr:
+			// pinger has already finished, deferred call must not wait for it
+			runErr <- err
+			return err
+		case rtt := <-received:
+			lastReceived = time.Now()
+			lastRtt = rtt
+		case <-ticker.C:
+			isOnline := time.Since(lastReceived) < observeOnlineTimeout
+			observeStatus(host, isOnline, lastRtt)
+
+			select {
+			case updates <- Status{IsOnline: isOnline}:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
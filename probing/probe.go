@@ -0,0 +1,296 @@
+// Package probing checks whether hosts are online using ICMP, TCP, ARP or
+// TCP from SSH jump host, and observes their status continuously.
+package probing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"homecontroller/metrics"
+	"homecontroller/sshctl"
+
+	"github.com/go-ping/ping"
+	"github.com/op/go-logging"
+	"golang.org/x/net/icmp"
+)
+
+var log = logging.MustGetLogger("probing")
+
+type Mode string
+
+const (
+	ModeAuto         Mode = "auto"
+	ModePrivileged   Mode = "privileged"
+	ModeUnprivileged Mode = "unprivileged"
+	ModeTcp          Mode = "tcp"
+	ModeArp          Mode = "arp"
+	ModeJump         Mode = "jump"
+)
+
+const (
+	probeTimeout  = 3 * time.Second
+	probeInterval = time.Second
+)
+
+var defaultProbePorts = []int{22, 80, 443, 445, 3389}
+
+func (m Mode) Validate() error {
+	switch m {
+	case "", ModeAuto, ModePrivileged, ModeUnprivileged, ModeTcp, ModeArp, ModeJump:
+		return nil
+	default:
+		return fmt.Errorf("unknown probe mode '%s'", m)
+	}
+}
+
+func (m Mode) isIcmp() bool {
+	return m == ModePrivileged || m == ModeUnprivileged
+}
+
+// Config selects how online status of host is checked. When mode is empty or
+// auto, mode detected on startup is used. Mode jump connects to ports from
+// the last of jumps, for hosts in network unreachable from the controller.
+type Config struct {
+	Mode  Mode
+	Ports []int
+	Jumps []sshctl.Destination
+}
+
+func (p Config) ResolvedMode() Mode {
+	if p.Mode == "" || p.Mode == ModeAuto {
+		return DetectCapabilities().Mode
+	}
+
+	return p.Mode
+}
+
+func (p Config) ports() []int {
+	if len(p.Ports) == 0 {
+		return defaultProbePorts
+	}
+
+	return p.Ports
+}
+
+// Key identifies probe of host, equal keys share observation.
+func (p Config) Key(host string) string {
+	key := fmt.Sprintf("%s|%s|%v", host, p.ResolvedMode(), p.Ports)
+	for _, jump := range p.Jumps {
+		key += "|" + jump.User + "@" + jump.Addr()
+	}
+
+	return key
+}
+
+type Capabilities struct {
+	Mode   Mode
+	Reason string
+}
+
+var (
+	probeCapabilitiesOnce sync.Once
+	probeCapabilities     Capabilities
+)
+
+// DetectCapabilities checks once which ICMP sockets can be opened by
+// the process, falling back to TCP/ARP probes when neither is usable.
+func DetectCapabilities() Capabilities {
+	probeCapabilitiesOnce.Do(func() {
+		rawConn, rawErr := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+		if rawErr == nil {
+			rawConn.Close()
+			probeCapabilities = Capabilities{ModePrivileged, "raw ICMP socket is available"}
+			return
+		}
+
+		udpConn, udpErr := icmp.ListenPacket("udp4", "0.0.0.0")
+		if udpErr == nil {
+			udpConn.Close()
+			probeCapabilities = Capabilities{ModeUnprivileged, fmt.Sprintf("raw ICMP socket is not available (%v), datagram ICMP socket is", rawErr)}
+			return
+		}
+
+		probeCapabilities = Capabilities{
+			ModeTcp,
+			fmt.Sprintf("neither raw ICMP (%v) nor datagram ICMP (%v) socket is available, check CAP_NET_RAW or net.ipv4.ping_group_range", rawErr, udpErr),
+		}
+	})
+
+	return probeCapabilities
+}
+
+func newIcmpPinger(host string, mode Mode) (*ping.Pinger, error) {
+	pinger, err := ping.NewPinger(host)
+	if err != nil {
+		return nil, err
+	}
+
+	pinger.SetPrivileged(mode == ModePrivileged)
+	return pinger, nil
+}
+
+// Online checks whether host is online using configured probe.
+func Online(ctx context.Context, host string, probe Config) (bool, error) {
+	if err := probe.Mode.Validate(); err != nil {
+		return false, err
+	}
+
+	mode := probe.ResolvedMode()
+
+	var isOnline bool
+	var rtt time.Duration
+	var err error
+	if mode == ModeJump {
+		isOnline, rtt, err = probeViaJumpOnce(ctx, host, probe)
+	} else if mode.isIcmp() {
+		isOnline, rtt, err = pingOnce(host, mode)
+	} else {
+		isOnline, rtt, err = probeOnce(ctx, host, mode, probe.ports())
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("couldnt probe host using %s mode, %s", mode, err)
+	}
+
+	observeStatus(host, isOnline, rtt)
+	return isOnline, nil
+}
+
+// observeStatus records probe in metrics under the same key as actions on
+// host, which is lower-cased host (controller.ActionKey).
+func observeStatus(host string, isOnline bool, rtt time.Duration) {
+	metrics.ObserveTargetStatus(strings.ToLower(host), isOnline, rtt)
+}
+
+func pingOnce(host string, mode Mode) (bool, time.Duration, error) {
+	pinger, err := newIcmpPinger(host, mode)
+	if err != nil {
+		return false, 0, err
+	}
+
+	pinger.Timeout = probeTimeout
+	pinger.OnRecv = func(packet *ping.Packet) {
+		pinger.Stop()
+	}
+
+	err = pinger.Run()
+	if err != nil {
+		return false, 0, err
+	}
+
+	stats := pinger.Statistics()
+	return stats.PacketsRecv > 0, stats.AvgRtt, nil
+}
+
+// probeOnce checks host without ICMP, TCP connect to any of ports succeeds
+// also when the connection is refused as the host had to answer. Mode tcp
+// falls back to ARP table lookup, which works for hosts on local network
+// with all probed ports filtered.
+func probeOnce(ctx context.Context, host string, mode Mode, ports []int) (bool, time.Duration, error) {
+	if mode == ModeTcp {
+		if isOnline, rtt := probeTcp(ctx, host, ports); isOnline {
+			return true, rtt, nil
+		}
+	}
+
+	isOnline, err := probeArp(host)
+	if err != nil && mode == ModeTcp {
+		// ARP is best effort fallback of TCP mode
+		return false, 0, nil
+	}
+
+	return isOnline, 0, err
+}
+
+func probeTcp(ctx context.Context, host string, ports []int) (bool, time.Duration) {
+	type result struct {
+		isOnline bool
+		rtt      time.Duration
+	}
+
+	results := make(chan result, len(ports))
+	for _, port := range ports {
+		go func() {
+			start := time.Now()
+			dialer := net.Dialer{Timeout: probeTimeout}
+			conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, fmt.Sprint(port)))
+			if err == nil {
+				conn.Close()
+			}
+			results <- result{err == nil || errors.Is(err, syscall.ECONNREFUSED), time.Since(start)}
+		}()
+	}
+
+	for range ports {
+		if r := <-results; r.isOnline {
+			return true, r.rtt
+		}
+	}
+
+	return false, 0
+}
+
+// tcpStatusPinger implements statusPinger by repeating non-ICMP probes.
+type tcpStatusPinger struct {
+	host  string
+	mode  Mode
+	ports []int
+
+	onReceive func(rtt time.Duration)
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+func (p *tcpStatusPinger) OnReceive(fn func(rtt time.Duration)) {
+	p.onReceive = fn
+}
+
+func (p *tcpStatusPinger) Run() error {
+	ticker := time.NewTicker(probeInterval)
+	defer ticker.Stop()
+
+	for {
+		isOnline, rtt, err := probeOnce(context.Background(), p.host, p.mode, p.ports)
+		if err != nil {
+			return err
+		}
+
+		if isOnline && p.onReceive != nil {
+			p.onReceive(rtt)
+		}
+
+		select {
+		case <-p.stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *tcpStatusPinger) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+}
+
+// ResolveIPv4 returns the first IPv4 address of host.
+func ResolveIPv4(host string) (net.IP, error) {
+	addrs, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, addr := range addrs {
+		if ip4 := addr.To4(); ip4 != nil {
+			return ip4, nil
+		}
+	}
+
+	return nil, fmt.Errorf("host %s has no IPv4 address", host)
+}
@@ -0,0 +1,105 @@
+package secret
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// errKeyringUnavailable is returned when there is no OS keyring to use,
+// secrets are kept in encrypted secrets file then.
+var errKeyringUnavailable = errors.New("OS keyring is unavailable")
+
+// keyringDisabled reports whether encrypted secrets file should be used even
+// when OS keyring exists, e.g. on headless servers without desktop session.
+func keyringDisabled() bool {
+	return os.Getenv("HOMECONTROLLER_KEYRING") == "file"
+}
+
+// OS keyring is used through its command line tools, secret-tool of
+// libsecret on Linux and security on macOS. Values are passed on stdin, so
+// they don't show up in process list.
+func keyringGet(service string, account string) (string, error) {
+	if keyringDisabled() {
+		return "", errKeyringUnavailable
+	}
+
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		out, err := runKeyringTool(nil, "secret-tool", "lookup", "service", service, "account", account)
+		if err != nil {
+			return "", err
+		}
+		if len(out) == 0 {
+			return "", fmt.Errorf("no secret for service %s and account %s in keyring", service, account)
+		}
+		return strings.TrimRight(string(out), "\n"), nil
+	case "darwin":
+		out, err := runKeyringTool(nil, "security", "find-generic-password", "-s", service, "-a", account, "-w")
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(out), "\n"), nil
+	default:
+		return "", errKeyringUnavailable
+	}
+}
+
+func keyringSet(service string, account string, value string) error {
+	if keyringDisabled() {
+		return errKeyringUnavailable
+	}
+
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		label := fmt.Sprintf("homecontroller %s/%s", service, account)
+		_, err := runKeyringTool([]byte(value), "secret-tool", "store", "--label", label, "service", service, "account", account)
+		return err
+	case "darwin":
+		if strings.ContainsAny(service+account, "\"\n") {
+			return errors.New("keyring service and account must not contain quotes")
+		}
+
+		// interactive mode reads command from stdin, value is hex encoded
+		command := fmt.Sprintf("add-generic-password -U -s \"%s\" -a \"%s\" -X %s\n", service, account, hex.EncodeToString([]byte(value)))
+		_, err := runKeyringTool([]byte(command), "security", "-i")
+		return err
+	default:
+		return errKeyringUnavailable
+	}
+}
+
+func runKeyringTool(stdin []byte, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, errKeyringUnavailable
+	}
+
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		// secret-tool without session bus has no keyring to talk to
+		if strings.Contains(message, "D-Bus") || strings.Contains(message, "dbus") {
+			return nil, errKeyringUnavailable
+		}
+		if message == "" {
+			return nil, fmt.Errorf("%s failed, %v", name, err)
+		}
+		return nil, fmt.Errorf("%s failed, %s", name, message)
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,179 @@
+// Package secret resolves secrets given in configuration either literally or
+// by reference:
+//
+//	env:VAR                  value of environment variable
+//	file:/run/secrets/x      content of file, trailing newline is dropped
+//	keyring:service/account  OS keyring, or encrypted secrets file without it
+//	plain:value              the value itself, for values looking like reference
+//
+// References are resolved when the secret is used, resolved values are never
+// logged.
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("secret")
+
+const (
+	schemeEnv     = "env"
+	schemeFile    = "file"
+	schemeKeyring = "keyring"
+	schemePlain   = "plain"
+)
+
+// PromptFunc asks user for secret, e.g. passphrase of encrypted secrets file.
+type PromptFunc func(question string) (string, error)
+
+var prompt PromptFunc
+
+// SetPrompt sets function used to ask for passphrase of encrypted secrets
+// file, when it is not in HOMECONTROLLER_SECRETS_PASSPHRASE. Nil disables
+// asking.
+func SetPrompt(p PromptFunc) {
+	prompt = p
+}
+
+// Ref is secret value or reference to it. String of Ref never contains the
+// value itself, so Ref may be logged.
+type Ref string
+
+// Plain returns Ref of value itself, which is never resolved as reference.
+func Plain(value string) Ref {
+	if value == "" {
+		return ""
+	}
+
+	return Ref(schemePlain + ":" + value)
+}
+
+func (r Ref) IsZero() bool {
+	return r == ""
+}
+
+// IsReference reports whether value is stored outside of the Ref.
+func (r Ref) IsReference() bool {
+	scheme, _ := r.split()
+	return scheme != "" && scheme != schemePlain
+}
+
+func (r Ref) split() (string, string) {
+	scheme, rest, ok := strings.Cut(string(r), ":")
+	if !ok {
+		return "", string(r)
+	}
+
+	switch scheme {
+	case schemeEnv, schemeFile, schemeKeyring, schemePlain:
+		return scheme, rest
+	}
+
+	return "", string(r)
+}
+
+func (r Ref) String() string {
+	if r.IsZero() {
+		return ""
+	}
+
+	if r.IsReference() {
+		return string(r)
+	}
+
+	return "<redacted>"
+}
+
+func (r Ref) GoString() string {
+	return fmt.Sprintf("secret.Ref(%q)", r.String())
+}
+
+// Resolve returns value of secret, reading it from referenced backend.
+func (r Ref) Resolve() (string, error) {
+	scheme, rest := r.split()
+	switch scheme {
+	case schemeEnv:
+		value, ok := os.LookupEnv(rest)
+		if !ok {
+			return "", fmt.Errorf("couldnt resolve secret %s, environment variable is not set", r)
+		}
+		return value, nil
+	case schemeFile:
+		bts, err := os.ReadFile(expandHomePath(rest))
+		if err != nil {
+			return "", fmt.Errorf("couldnt resolve secret %s, %v", r, err)
+		}
+		return strings.TrimRight(string(bts), "\r\n"), nil
+	case schemeKeyring:
+		service, account, err := splitKeyringName(rest)
+		if err != nil {
+			return "", err
+		}
+
+		value, err := keyringGet(service, account)
+		if errors.Is(err, errKeyringUnavailable) {
+			log.Debugf("OS keyring is unavailable, using encrypted secrets file for %s", r)
+			value, err = DefaultStore.Get(rest)
+		}
+		if err != nil {
+			return "", fmt.Errorf("couldnt resolve secret %s, %v", r, err)
+		}
+		return value, nil
+	default:
+		return rest, nil
+	}
+}
+
+// Set stores value in backend referenced by ref.
+func Set(ref Ref, value string) error {
+	scheme, rest := ref.split()
+	switch scheme {
+	case schemeEnv:
+		return fmt.Errorf("environment variable cannot be set, export %s before running homecontroller", rest)
+	case schemeFile:
+		path := expandHomePath(rest)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return fmt.Errorf("couldnt create directory of %s, %v", path, err)
+		}
+		return os.WriteFile(path, []byte(value+"\n"), 0600)
+	case schemeKeyring:
+		service, account, err := splitKeyringName(rest)
+		if err != nil {
+			return err
+		}
+
+		err = keyringSet(service, account, value)
+		if errors.Is(err, errKeyringUnavailable) {
+			log.Infof("OS keyring is unavailable, storing %s in encrypted secrets file", ref)
+			return DefaultStore.Set(rest, value)
+		}
+		return err
+	default:
+		return errors.New("secret is not a reference, use env:, file: or keyring:")
+	}
+}
+
+func splitKeyringName(name string) (string, string, error) {
+	service, account, ok := strings.Cut(name, "/")
+	if !ok || service == "" || account == "" {
+		return "", "", fmt.Errorf("invalid keyring reference '%s', expected keyring:service/account", name)
+	}
+
+	return service, account, nil
+}
+
+func expandHomePath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if dirname, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(dirname, path[1:])
+		}
+	}
+
+	return path
+}
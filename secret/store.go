@@ -0,0 +1,237 @@
+package secret
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	storeRelativePath = ".homecontroller/secrets.enc"
+	storeVersion      = 1
+
+	// scrypt parameters recommended for interactive logins
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// DefaultStore is encrypted secrets file used when OS keyring is unavailable.
+var DefaultStore = &Store{}
+
+// Store is file of secrets encrypted by passphrase. Passphrase is taken from
+// HOMECONTROLLER_SECRETS_PASSPHRASE or asked for by prompt, decrypted
+// secrets are kept in memory afterwards.
+type Store struct {
+	// Path of the file, ~/.homecontroller/secrets.enc when empty
+	Path string
+
+	mu         sync.Mutex
+	passphrase string
+	secrets    map[string]string
+}
+
+type storeFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+func (s *Store) path() (string, error) {
+	if s.Path != "" {
+		return s.Path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, storeRelativePath), nil
+}
+
+func (s *Store) Get(name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(false); err != nil {
+		return "", err
+	}
+
+	value, ok := s.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("no secret '%s' in encrypted secrets file", name)
+	}
+
+	return value, nil
+}
+
+func (s *Store) Set(name string, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(true); err != nil {
+		return err
+	}
+
+	s.secrets[name] = value
+	return s.save()
+}
+
+// load decrypts secrets file unless it was decrypted already. Missing file
+// is empty store when create is set.
+func (s *Store) load(create bool) error {
+	if s.secrets != nil {
+		return nil
+	}
+
+	path, err := s.path()
+	if err != nil {
+		return err
+	}
+
+	bts, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && create {
+		passphrase, err := s.askPassphrase(fmt.Sprintf("New passphrase for %s: ", path))
+		if err != nil {
+			return err
+		}
+
+		if _, ok := os.LookupEnv("HOMECONTROLLER_SECRETS_PASSPHRASE"); !ok {
+			repeated, err := s.askPassphrase("Repeat passphrase: ")
+			if err != nil {
+				return err
+			}
+			if repeated != passphrase {
+				return errors.New("passphrases do not match")
+			}
+		}
+
+		s.passphrase = passphrase
+		s.secrets = make(map[string]string)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("couldnt read encrypted secrets file, %v", err)
+	}
+
+	var file storeFile
+	if err := json.Unmarshal(bts, &file); err != nil {
+		return fmt.Errorf("couldnt parse encrypted secrets file %s, %v", path, err)
+	}
+	if file.Version != storeVersion {
+		return fmt.Errorf("unsupported version %d of encrypted secrets file %s", file.Version, path)
+	}
+
+	passphrase, err := s.askPassphrase(fmt.Sprintf("Passphrase for %s: ", path))
+	if err != nil {
+		return err
+	}
+
+	aead, err := newStoreCipher(passphrase, file.Salt)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := aead.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		return fmt.Errorf("couldnt decrypt %s, wrong passphrase", path)
+	}
+
+	var secrets map[string]string
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return fmt.Errorf("couldnt parse decrypted secrets, %v", err)
+	}
+	if secrets == nil {
+		secrets = make(map[string]string)
+	}
+
+	s.passphrase = passphrase
+	s.secrets = secrets
+	return nil
+}
+
+func (s *Store) save() error {
+	path, err := s.path()
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(s.secrets)
+	if err != nil {
+		return err
+	}
+
+	file := storeFile{
+		Version: storeVersion,
+		Salt:    make([]byte, 16),
+		Nonce:   make([]byte, chacha20poly1305.NonceSizeX),
+	}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+
+	aead, err := newStoreCipher(s.passphrase, file.Salt)
+	if err != nil {
+		return err
+	}
+	file.Data = aead.Seal(nil, file.Nonce, plaintext, nil)
+
+	bts, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("couldnt create directory of %s, %v", path, err)
+	}
+
+	// replace file at once, so that it is never half written
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, bts, 0600); err != nil {
+		return fmt.Errorf("couldnt write encrypted secrets file, %v", err)
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+func (s *Store) askPassphrase(question string) (string, error) {
+	if passphrase, ok := os.LookupEnv("HOMECONTROLLER_SECRETS_PASSPHRASE"); ok {
+		return passphrase, nil
+	}
+
+	if prompt == nil {
+		return "", errors.New("passphrase of encrypted secrets file is required, set HOMECONTROLLER_SECRETS_PASSPHRASE")
+	}
+
+	passphrase, err := prompt(question)
+	if err != nil {
+		return "", fmt.Errorf("couldnt read passphrase, %v", err)
+	}
+	if passphrase == "" {
+		return "", errors.New("passphrase must not be empty")
+	}
+
+	return passphrase, nil
+}
+
+func newStoreCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("couldnt derive key from passphrase, %v", err)
+	}
+
+	return chacha20poly1305.NewX(key)
+}
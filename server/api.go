@@ -0,0 +1,502 @@
+// Package server serves the HTTP API controlling registered targets. The API
+// is an http.Handler, so it can be mounted into other servers as well.
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"homecontroller/config"
+	"homecontroller/metrics"
+
+	"github.com/gorilla/mux"
+	"github.com/op/go-logging"
+	"golang.org/x/net/websocket"
+)
+
+var log = logging.MustGetLogger("server")
+
+type httpApiHandler struct {
+	router *mux.Router
+
+	httpAddr                       string
+	httpsAddr, httpsCert, httpsKey string
+
+	// configMu guards targets and authToken, which are replaced on reload
+	configMu  sync.RWMutex
+	targets   []config.TargetConfiguration
+	authToken string
+
+	// adhocSsh lets API callers reach unregistered hosts with ssh_config
+	// and ssh-agent of server
+	adhocSsh bool
+
+	// serveCtx is context given to Serve, background work of requests stops
+	// with it
+	serveCtx context.Context
+
+	version  string
+	jobs     *jobManager
+	sessions *sessionStore
+
+	openApiState
+}
+
+// HttpCore is the API handler together with its own listeners. Handler can
+// be used without Serve, e.g. when mounted into other server.
+type HttpCore interface {
+	http.Handler
+	SetHttp(httpAddr string)
+	SetHttps(httpsAddr string, httpsCert string, httpsKey string)
+	SetTargets(targets []config.TargetConfiguration)
+	SetVersion(version string)
+	SetJobRetention(retention time.Duration)
+	SetAuthToken(authToken string)
+	AllowAdhocSsh()
+	EnableDashboard()
+	UseMiddleware(mwf ...mux.MiddlewareFunc)
+	Serve(ctx context.Context) error
+}
+
+func InitApiCore() HttpCore {
+	handler := &httpApiHandler{
+		serveCtx: context.Background(),
+		version:  "dev",
+		jobs:     newJobManager(DefaultJobRetention),
+		sessions: newSessionStore(),
+	}
+	handler.router = newRouter(handler)
+	handler.router.Use(handler.authMiddleware)
+	return handler
+}
+
+// redactedBodyRoutes receive secrets in request body.
+var redactedBodyRoutes = map[string]bool{
+	"login": true,
+	"halt":  true,
+}
+
+type responder struct {
+	r *http.Request
+	w http.ResponseWriter
+
+	start        time.Time
+	statusCode   int
+	headers      map[string]string
+	cookies      []*http.Cookie
+	responseBody interface{}
+}
+
+func (r *responder) setHeader(name, value string) {
+	if r.headers == nil {
+		r.headers = make(map[string]string)
+	}
+
+	r.headers[name] = value
+}
+
+func (r *responder) handle(rp RequestProcessor) {
+	r.start = time.Now()
+
+	bodyBts, _ := io.ReadAll(r.r.Body)
+	r.r.Body.Close()
+
+	loggedBody := string(bodyBts)
+	if redactedBodyRoutes[currentRouteName(r.r)] {
+		// token and passwords must not end up in log
+		loggedBody = "<redacted>"
+	}
+
+	msg := fmt.Sprintf("Request: '%s %s', body: '%s'.", r.r.Method, r.r.RequestURI, loggedBody)
+	log.Debug(msg)
+
+	r.r.Body = io.NopCloser(bytes.NewBuffer(bodyBts))
+
+	res, err := rp(r.r)
+	if err != nil {
+		r.setErrors(err)
+	} else {
+		r.setSuccess(res)
+	}
+
+	_ = r.sendResponse()
+	r.outputToLog()
+}
+
+func (r *responder) sendResponse() error {
+	for name, value := range r.headers {
+		r.w.Header().Set(name, value)
+	}
+
+	for _, cookie := range r.cookies {
+		http.SetCookie(r.w, cookie)
+	}
+
+	if r.responseBody != nil {
+		r.w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+
+	r.w.WriteHeader(r.statusCode)
+	if r.responseBody != nil {
+		return json.NewEncoder(r.w).Encode(r.responseBody)
+	}
+
+	return nil
+}
+
+func (r *responder) outputToLog() {
+	processingTime := time.Now().Sub(r.start)
+	var extra string
+	if respErr, ok := r.responseBody.(responseError); ok {
+		extra = fmt.Sprintf("'%s'", respErr.Message)
+	}
+
+	msg := fmt.Sprintf("Response to: '%s %s', response: %d %s (took: %v).", r.r.Method, r.r.RequestURI, r.statusCode, extra, processingTime)
+	log.Info(msg)
+
+	routeName := currentRouteName(r.r)
+	if routeName == "" {
+		routeName = "unknown"
+	}
+	metrics.ObserveHttpRequest(routeName, r.statusCode, processingTime)
+}
+
+// acceptedResponse is returned by processors which only started the work,
+// location points to resource reporting its progress.
+type acceptedResponse struct {
+	body     interface{}
+	location string
+}
+
+// cookieResponse sets cookie on otherwise empty response.
+type cookieResponse struct {
+	cookie *http.Cookie
+}
+
+func (r *responder) setSuccess(obj interface{}) {
+	if response, ok := obj.(cookieResponse); ok {
+		r.statusCode = http.StatusNoContent
+		r.cookies = append(r.cookies, response.cookie)
+		return
+	}
+
+	if accepted, ok := obj.(acceptedResponse); ok {
+		r.statusCode = http.StatusAccepted
+		r.responseBody = accepted.body
+		r.setHeader("Location", accepted.location)
+		return
+	}
+
+	if obj == nil {
+		r.statusCode = http.StatusNoContent
+	} else {
+		r.statusCode = http.StatusOK
+	}
+
+	r.responseBody = obj
+}
+
+type responseError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Fields  string      `json:"fields"`
+	Result  interface{} `json:"result,omitempty"`
+}
+
+func newResponseError(err error) responseError {
+	var result interface{}
+	if resultErr, ok := err.(resultError); ok {
+		err, result = resultErr.error, resultErr.result
+	}
+
+	errObj := responseError{
+		http.StatusBadRequest,
+		err.Error(),
+		"",
+		result,
+	}
+
+	if httpError, ok := err.(HttpError); ok {
+		errObj.Code = httpError.StatusCode()
+	}
+
+	if fieldsError, ok := err.(FieldError); ok {
+		errObj.Fields = fieldsError.Fields()
+	}
+
+	return errObj
+}
+
+func (r *responder) setErrors(err error) {
+	errObj := newResponseError(err)
+
+	r.statusCode = errObj.Code
+	r.responseBody = errObj
+}
+
+type RequestProcessor func(r *http.Request) (interface{}, error)
+
+func (rp RequestProcessor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	resp := responder{r: r, w: w}
+	resp.handle(rp)
+}
+
+type HttpError interface {
+	StatusCode() int
+}
+
+type FieldError interface {
+	Fields() string
+}
+
+// Unauthorized
+type invalidTokenError struct {
+	error
+}
+
+func (e invalidTokenError) StatusCode() int {
+	return http.StatusUnauthorized
+}
+
+func (e invalidTokenError) Fields() string {
+	return "token"
+}
+
+// Forbidden
+type forbiddenError struct {
+	error
+}
+
+func (e forbiddenError) StatusCode() int {
+	return http.StatusForbidden
+}
+
+// Bad Request
+type badRequestError struct {
+	error
+}
+
+func (e badRequestError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// Not Found
+type notFoundError struct {
+	error
+}
+
+func (e notFoundError) StatusCode() int {
+	return http.StatusNotFound
+}
+
+// Internal Error
+type internalError struct {
+	error
+}
+
+func (e internalError) StatusCode() int {
+	return http.StatusInternalServerError
+}
+
+type conflictError struct {
+	message      string
+	conflictCode string
+}
+
+func (e conflictError) StatusCode() int {
+	return http.StatusConflict
+}
+
+func (e conflictError) Fields() string {
+	return e.conflictCode
+}
+
+func (e conflictError) Error() string {
+	return e.message
+}
+
+// resultError is failed action which still has result, e.g. hooks which ran
+// before it failed.
+type resultError struct {
+	error
+	result interface{}
+}
+
+// Base error
+type baseHttpError struct {
+	error
+	statusCode int
+	fields     string
+}
+
+func (e baseHttpError) StatusCode() int {
+	return e.statusCode
+}
+
+func (e baseHttpError) Fields() string {
+	return e.fields
+}
+
+func newRouter(h *httpApiHandler) *mux.Router {
+	router := mux.NewRouter().StrictSlash(false)
+
+	for _, r := range h.getRoutes() {
+		router.Methods(r.Method).
+			Path(r.Pattern).
+			Name(r.Name).
+			Handler(r.HandlerFunc)
+	}
+
+	for _, r := range h.getWSRoutes() {
+		router.Handle(r.Path, websocket.Handler(r.HandlerFunc)).
+			Name(r.Name)
+	}
+
+	router.Methods("GET").
+		Path("/events").
+		Name("events").
+		HandlerFunc(h.EventStream)
+
+	router.Methods("GET").
+		Path("/metrics").
+		Name("metrics").
+		Handler(metrics.Handler())
+
+	router.Methods("GET").
+		Path("/openapi.json").
+		Name("openapi").
+		HandlerFunc(h.OpenApi)
+
+	return router
+}
+
+func (h *httpApiHandler) SetHttp(httpAddr string) {
+	h.httpAddr = httpAddr
+}
+
+func (h *httpApiHandler) SetHttps(httpsAddr string, httpsCert string, httpsKey string) {
+	h.httpsAddr = httpsAddr
+	h.httpsCert = httpsCert
+	h.httpsKey = httpsKey
+}
+
+func (h *httpApiHandler) SetTargets(targets []config.TargetConfiguration) {
+	h.configMu.Lock()
+	defer h.configMu.Unlock()
+
+	h.targets = targets
+}
+
+func (h *httpApiHandler) currentTargets() []config.TargetConfiguration {
+	h.configMu.RLock()
+	defer h.configMu.RUnlock()
+
+	return h.targets
+}
+
+// AllowAdhocSsh resolves hosts given by API callers against ~/.ssh/config,
+// offers them ssh-agent keys and lets callers manage their known_hosts
+// entries, even when they are not registered targets.
+func (h *httpApiHandler) AllowAdhocSsh() {
+	h.adhocSsh = true
+}
+
+// sshHostAllowed reports whether API caller may reach host with SSH settings
+// of server, that is host or jump host of registered target.
+func (h *httpApiHandler) sshHostAllowed(host string) bool {
+	if h.adhocSsh {
+		return true
+	}
+
+	for _, target := range h.currentTargets() {
+		if strings.EqualFold(target.Host, host) {
+			return true
+		}
+		for _, jump := range target.Ssh.ProxyJump {
+			if strings.EqualFold(jump.Host, host) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (h *httpApiHandler) SetVersion(version string) {
+	h.version = version
+}
+
+func (h *httpApiHandler) SetJobRetention(retention time.Duration) {
+	h.jobs.SetRetention(retention)
+}
+
+func (h *httpApiHandler) getTarget(id string) *config.TargetConfiguration {
+	return config.TargetById(h.currentTargets(), id)
+}
+
+func (h *httpApiHandler) UseMiddleware(mwf ...mux.MiddlewareFunc) {
+	h.router.Use(mwf...)
+}
+
+func (h *httpApiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+// Serve listens on configured addresses until ctx is cancelled, when
+// listeners are shut down gracefully, or until any listener fails.
+func (h *httpApiHandler) Serve(ctx context.Context) error {
+	if h.httpAddr == "" && h.httpsAddr == "" {
+		return errors.New("either HTTP or/and HTTPS must be enabled")
+	}
+	h.serveCtx = ctx
+
+	var servers []*http.Server
+	errs := make(chan error, 2)
+
+	if h.httpAddr != "" {
+		server := &http.Server{Addr: h.httpAddr, Handler: h}
+		servers = append(servers, server)
+		go func() {
+			log.Infof("HTTP: Listening on addr %s", server.Addr)
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				errs <- fmt.Errorf("couldnt start HTTP listener, %v", err)
+			}
+		}()
+	}
+
+	if h.httpsCert != "" && h.httpsKey != "" {
+		server := &http.Server{Addr: h.httpsAddr, Handler: h}
+		servers = append(servers, server)
+		go func() {
+			log.Infof("HTTPS: Listening on addr %s", server.Addr)
+			if err := server.ListenAndServeTLS(h.httpsCert, h.httpsKey); err != nil && err != http.ErrServerClosed {
+				errs <- fmt.Errorf("couldnt start HTTPS listener, %v", err)
+			}
+		}()
+	} else {
+		log.Warning("To enable HTTPS server you must provide both cert and key file.")
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-errs:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, server := range servers {
+		server.Shutdown(shutdownCtx)
+	}
+
+	return err
+}
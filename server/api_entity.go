@@ -0,0 +1,177 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"homecontroller/config"
+	"homecontroller/secret"
+	"homecontroller/sshctl"
+)
+
+type Validation interface {
+	Validate() error
+}
+
+type ApiWakePayload struct {
+	Mac config.HwAddress `json:"mac"`
+
+	BroadcastAddress []*config.BroadcastAddress `json:"addresses,omitempty"`
+
+	// Host and VerifyTimeout (in seconds) enable verification that the
+	// target actually came online after the magic packet was sent.
+	Host          string `json:"host,omitempty"`
+	VerifyTimeout int    `json:"verify_timeout,omitempty"`
+}
+
+func (w *ApiWakePayload) Validate() error {
+	toValidate := []Validation{w.Mac}
+
+	for _, a := range w.BroadcastAddress {
+		toValidate = append(toValidate, a)
+	}
+
+	for _, o := range toValidate {
+		if err := o.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *ApiWakePayload) GetMac() string {
+	return string(w.Mac)
+}
+
+func (w *ApiWakePayload) GetBroadcastAddress() []*config.BroadcastAddress {
+	return w.BroadcastAddress
+}
+
+// ApiHaltPayload describes SSH connection to halted host. User and
+// credentials may be omitted when server's ~/.ssh/config or ssh-agent
+// provides them, which server uses only for hosts of registered targets.
+// Jump hosts must be hosts of registered targets.
+type ApiHaltPayload struct {
+	User        string                        `json:"user,omitempty"`
+	Host        string                        `json:"host,required"`
+	Port        *int                          `json:"port,omitempty"`
+	Password    config.Password               `json:"password,omitempty"`
+	PrivateKey  config.SshPrivateKeyOptions   `json:"private_key,omitempty"`
+	Certificate string                        `json:"certificate,omitempty"`
+	HostKey     string                        `json:"host_key,omitempty"`
+	ProxyJump   []config.SshJumpConfiguration `json:"proxy_jump,omitempty"`
+}
+
+func (h *ApiHaltPayload) Validate() error {
+	if len(h.Host) == 0 {
+		return errors.New("host must not be empty")
+	}
+
+	return nil
+}
+
+// sshDestination returns destination of payload. Secrets of payload are
+// values themselves, references must never be resolved on server.
+func (h *ApiHaltPayload) sshDestination() sshctl.Destination {
+	dest := plainSecrets(sshctl.Destination{
+		User:        h.User,
+		Host:        h.Host,
+		Port:        h.Port,
+		Password:    secret.Ref(h.Password),
+		PrivateKey:  h.PrivateKey.Key(),
+		Certificate: h.Certificate,
+		HostKey:     h.HostKey,
+	})
+
+	for _, jump := range config.JumpDestinations(h.ProxyJump) {
+		dest.Jumps = append(dest.Jumps, plainSecrets(jump))
+	}
+
+	return dest
+}
+
+func plainSecrets(dest sshctl.Destination) sshctl.Destination {
+	dest.Password = secret.Plain(string(dest.Password))
+	if dest.PrivateKey != nil {
+		dest.PrivateKey.Passphrase = secret.Plain(string(dest.PrivateKey.Passphrase))
+	}
+
+	return dest
+}
+
+type ApiVersionData struct {
+	Version string `json:"version"`
+}
+
+type ApiTargetData struct {
+	Id       string           `json:"id"`
+	Host     string           `json:"host"`
+	Mac      config.HwAddress `json:"mac"`
+	Commands []string         `json:"commands"`
+}
+
+func newApiTargetData(target *config.TargetConfiguration) ApiTargetData {
+	commands := make([]string, 0, len(target.Commands))
+	for name := range target.Commands {
+		commands = append(commands, name)
+	}
+	sort.Strings(commands)
+
+	return ApiTargetData{
+		Id:       target.Id,
+		Host:     target.Host,
+		Mac:      target.Mac,
+		Commands: commands,
+	}
+}
+
+type ApiLoginPayload struct {
+	Token string `json:"token"`
+}
+
+type ApiHostKeyData struct {
+	Host        string `json:"host"`
+	Type        string `json:"type"`
+	Fingerprint string `json:"fingerprint"`
+	Known       bool   `json:"known"`
+	Changed     bool   `json:"changed"`
+}
+
+func newApiHostKeyData(info sshctl.HostKeyInfo) ApiHostKeyData {
+	return ApiHostKeyData(info)
+}
+
+// ApiTrustHostKeyPayload confirms fingerprint shown to user, key is
+// recorded only when server receives the same key again.
+type ApiTrustHostKeyPayload struct {
+	Host        string `json:"host,required"`
+	Port        *int   `json:"port,omitempty"`
+	Fingerprint string `json:"fingerprint,required"`
+}
+
+func (p *ApiTrustHostKeyPayload) Validate() error {
+	if len(p.Host) == 0 {
+		return errors.New("host must not be empty")
+	}
+
+	if len(p.Fingerprint) == 0 {
+		return errors.New("fingerprint must not be empty")
+	}
+
+	return nil
+}
+
+type ApiJobData struct {
+	Id       string          `json:"id"`
+	Action   string          `json:"action"`
+	Target   string          `json:"target"`
+	State    string          `json:"state"`
+	Progress string          `json:"progress,omitempty"`
+	Created  time.Time       `json:"created"`
+	Finished *time.Time      `json:"finished,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+	Error    *responseError  `json:"error,omitempty"`
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"homecontroller/config"
+	"homecontroller/controller"
+	"homecontroller/probing"
+	"homecontroller/sshctl"
+)
+
+func (h *httpApiHandler) Wake(r *http.Request) (interface{}, error) {
+	wakePayload, err := parseWakePayload(r)
+	if err != nil {
+		return nil, err
+	}
+
+	key := controller.ActionKey(wakePayload.Host, string(wakePayload.Mac))
+	finish, err := controller.BeginAction(key, "wake")
+	if err != nil {
+		return nil, actionHttpError(err)
+	}
+	defer finish()
+
+	err = controller.SendMagicPacket(r.Context(), wakePayload)
+	controller.RecordAction("wake", key, key, err)
+	if err != nil {
+		return nil, internalError{err}
+	}
+
+	if wakePayload.Host != "" && wakePayload.VerifyTimeout > 0 {
+		controller.VerifyWake(h.serveCtx, key, wakePayload.Host, probing.Config{}, time.Duration(wakePayload.VerifyTimeout)*time.Second)
+	}
+
+	// sends magic packet
+	return nil, nil
+}
+
+func (h *httpApiHandler) Halt(r *http.Request) (interface{}, error) {
+	haltPayload, err := parseHaltPayload(r)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, jump := range haltPayload.ProxyJump {
+		if !h.sshHostAllowed(jump.Host) {
+			return nil, forbiddenError{fmt.Errorf("proxy_jump host %s is not host of registered target", jump.Host)}
+		}
+	}
+
+	dest := haltPayload.sshDestination()
+	dest.Explicit = !h.sshHostAllowed(haltPayload.Host)
+
+	key := controller.ActionKey(haltPayload.Host, "")
+	finish, err := controller.BeginAction(key, "halt")
+	if err != nil {
+		return nil, actionHttpError(err)
+	}
+	defer finish()
+
+	err = sshctl.Halt(r.Context(), dest, nil)
+	controller.RecordAction("halt", key, key, err)
+	if err != nil {
+		return nil, sshHttpError(err)
+	}
+
+	return nil, nil
+}
+
+func (h *httpApiHandler) WakeTarget(r *http.Request) (interface{}, error) {
+	target, err := h.requireTarget(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.runApiAction(r, target, "wake", func(ctx context.Context) (interface{}, error) {
+		result, err := controller.Wake(ctx, target, nil)
+		if err != nil {
+			return result, actionHttpError(err)
+		}
+		return result, nil
+	})
+}
+
+func (h *httpApiHandler) HaltTarget(r *http.Request) (interface{}, error) {
+	target, err := h.requireTarget(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.runApiAction(r, target, "halt", func(ctx context.Context) (interface{}, error) {
+		result, err := controller.Halt(ctx, target, nil)
+		if err != nil {
+			return result, actionHttpError(err)
+		}
+		return result, nil
+	})
+}
+
+func (h *httpApiHandler) RebootTarget(r *http.Request) (interface{}, error) {
+	target, err := h.requireTarget(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.runApiAction(r, target, "reboot", func(ctx context.Context) (interface{}, error) {
+		result, err := controller.Reboot(ctx, target, nil)
+		if err != nil {
+			return result, actionHttpError(err)
+		}
+		return result, nil
+	})
+}
+
+// Targets lists registered targets, credentials are not included.
+func (h *httpApiHandler) Targets(r *http.Request) (interface{}, error) {
+	registered := h.currentTargets()
+	targets := make([]ApiTargetData, 0, len(registered))
+	for i := range registered {
+		targets = append(targets, newApiTargetData(&registered[i]))
+	}
+
+	return targets, nil
+}
+
+// Exec runs command configured on registered target, command text itself is
+// never accepted from request.
+func (h *httpApiHandler) Exec(r *http.Request) (interface{}, error) {
+	target, err := h.requireTarget(r)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := requirePathParam(r, "name")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := target.Commands[name]; !ok {
+		return nil, notFoundError{controller.UnknownCommandError{Target: target.Id, Name: name}}
+	}
+
+	return h.runApiAction(r, target, "exec", func(ctx context.Context) (interface{}, error) {
+		result, err := controller.Exec(ctx, target, name, nil)
+		if err != nil {
+			if controller.FailureReason(err) == "timeout" {
+				return result, baseHttpError{err, http.StatusGatewayTimeout, "timeout"}
+			}
+			return result, actionHttpError(err)
+		}
+		return result, nil
+	})
+}
+
+// runApiAction runs action within request, or as job when async is
+// requested. Job is not started when target is busy.
+func (h *httpApiHandler) runApiAction(r *http.Request, target *config.TargetConfiguration, action string, run func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if !parseAsyncParam(r) {
+		result, err := run(r.Context())
+		if err != nil {
+			return nil, resultError{err, result}
+		}
+		return result, nil
+	}
+
+	if err := controller.CheckAction(controller.TargetKey(target), action); err != nil {
+		return nil, actionHttpError(err)
+	}
+
+	job := h.jobs.Start(action, target.Id, run)
+	return acceptedResponse{job, fmt.Sprintf("/jobs/%s", job.Id)}, nil
+}
+
+func (h *httpApiHandler) Jobs(r *http.Request) (interface{}, error) {
+	return h.jobs.List(), nil
+}
+
+func (h *httpApiHandler) Job(r *http.Request) (interface{}, error) {
+	id, err := requirePathParam(r, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	job, ok := h.jobs.Get(id)
+	if !ok {
+		return nil, notFoundError{fmt.Errorf("job %s not found", id)}
+	}
+
+	return job, nil
+}
+
+func (h *httpApiHandler) CancelJob(r *http.Request) (interface{}, error) {
+	id, err := requirePathParam(r, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	job, ok := h.jobs.Cancel(id)
+	if !ok {
+		return nil, notFoundError{fmt.Errorf("job %s not found", id)}
+	}
+
+	return job, nil
+}
+
+func (h *httpApiHandler) Version(r *http.Request) (interface{}, error) {
+	return ApiVersionData{Version: h.version}, nil
+}
+
+func (h *httpApiHandler) Status(r *http.Request) (interface{}, error) {
+	host, err := requirePathParam(r, "host")
+	if err != nil {
+		return nil, err
+	}
+
+	statusData, err := probing.DefaultHub.Status(r.Context(), host, probing.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	return statusData, nil
+}
+
+func (h *httpApiHandler) requireTarget(r *http.Request) (*config.TargetConfiguration, error) {
+	id, err := requirePathParam(r, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	target := h.getTarget(id)
+	if target == nil {
+		return nil, notFoundError{fmt.Errorf("target %s not found", id)}
+	}
+
+	return target, nil
+}
+
+// actionHttpError reports busy target and action aborted by hook as
+// conflict, other failures as internal errors.
+func actionHttpError(err error) error {
+	var busyErr controller.BusyError
+	if errors.As(err, &busyErr) {
+		return conflictError{busyErr.Error(), "target_busy"}
+	}
+
+	switch reason := controller.FailureReason(err); reason {
+	case "hook", "unknown_host_key", "host_key_changed":
+		return conflictError{err.Error(), reason}
+	}
+
+	return internalError{err}
+}
+
+// sshHttpError reports host key problems as conflict, so that client can
+// resolve them via known hosts API.
+func sshHttpError(err error) error {
+	switch reason := controller.FailureReason(err); reason {
+	case "unknown_host_key", "host_key_changed":
+		return conflictError{err.Error(), reason}
+	}
+
+	return err
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"testing"
+
+	"homecontroller/config"
+)
+
+func TestSshHostAllowed(t *testing.T) {
+	handler := InitApiCore().(*httpApiHandler)
+	handler.SetTargets([]config.TargetConfiguration{{
+		Id:   "nas",
+		Host: "nas.lan",
+		Ssh:  config.SshConfiguration{ProxyJump: []config.SshJumpConfiguration{{Host: "bastion.lan"}}},
+	}})
+
+	tests := []struct {
+		host    string
+		allowed bool
+	}{
+		{"nas.lan", true},
+		{"NAS.lan", true},
+		{"bastion.lan", true},
+		{"attacker.example", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if allowed := handler.sshHostAllowed(test.host); allowed != test.allowed {
+			t.Errorf("host %q allowed %v, want %v", test.host, allowed, test.allowed)
+		}
+	}
+
+	handler.AllowAdhocSsh()
+	if !handler.sshHostAllowed("attacker.example") {
+		t.Error("unregistered host is not allowed with ad-hoc SSH")
+	}
+}
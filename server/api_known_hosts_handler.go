@@ -0,0 +1,97 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"homecontroller/sshctl"
+)
+
+func (h *httpApiHandler) KnownHost(r *http.Request) (interface{}, error) {
+	host, err := requirePathParam(r, "host")
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := parsePortQueryParam(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.requireSshHost(host); err != nil {
+		return nil, err
+	}
+
+	_, info, err := sshctl.InspectHostKey(r.Context(), sshctl.Destination{Host: host, Port: port})
+	if err != nil {
+		return nil, internalError{err}
+	}
+
+	return newApiHostKeyData(info), nil
+}
+
+func (h *httpApiHandler) TrustKnownHost(r *http.Request) (interface{}, error) {
+	payload, err := parseTrustHostKeyPayload(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.requireSshHost(payload.Host); err != nil {
+		return nil, err
+	}
+
+	key, info, err := sshctl.InspectHostKey(r.Context(), sshctl.Destination{Host: payload.Host, Port: payload.Port})
+	if err != nil {
+		return nil, internalError{err}
+	}
+
+	if info.Fingerprint != payload.Fingerprint {
+		return nil, conflictError{fmt.Sprintf("server presented host key %s, not %s", info.Fingerprint, payload.Fingerprint), "fingerprint_mismatch"}
+	}
+
+	if err := sshctl.TrustHostKey(key, info); err != nil {
+		return nil, internalError{err}
+	}
+
+	info.Known = true
+	info.Changed = false
+	return newApiHostKeyData(info), nil
+}
+
+func (h *httpApiHandler) RemoveKnownHost(r *http.Request) (interface{}, error) {
+	host, err := requirePathParam(r, "host")
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := parsePortQueryParam(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.requireSshHost(host); err != nil {
+		return nil, err
+	}
+
+	addr := sshctl.Destination{Host: host, Port: port}.Resolve().Addr()
+	removed, err := sshctl.RemoveKnownHost(addr)
+	if err != nil {
+		return nil, internalError{err}
+	}
+
+	if removed == 0 {
+		return nil, notFoundError{fmt.Errorf("host %s is not in known_hosts", addr)}
+	}
+
+	return nil, nil
+}
+
+// requireSshHost limits known hosts of server to hosts of registered targets,
+// unless ad-hoc SSH is allowed.
+func (h *httpApiHandler) requireSshHost(host string) error {
+	if !h.sshHostAllowed(host) {
+		return forbiddenError{fmt.Errorf("host %s is not host of registered target", host)}
+	}
+
+	return nil
+}
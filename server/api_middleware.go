@@ -0,0 +1,26 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+func bearerToken(r *http.Request) (string, bool) {
+	rawToken := r.Header.Get("Authorization")
+	tokenParts := strings.Split(rawToken, " ")
+	if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+		return "", false
+	}
+
+	return tokenParts[1], true
+}
+
+func currentRouteName(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		return route.GetName()
+	}
+
+	return ""
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+func parseWakePayload(r *http.Request) (*ApiWakePayload, error) {
+	allowedJsonMimeTypes := []string{"application/json"}
+
+	contentTypeHeaderValue := r.Header.Get("Content-Type")
+	mimeType, _, err := mime.ParseMediaType(contentTypeHeaderValue)
+	if err != nil || !slices.Contains(allowedJsonMimeTypes, mimeType) {
+		return nil, badRequestError{errors.New("invalid content-type")}
+	}
+
+	var wakePayload ApiWakePayload
+	err = json.NewDecoder(r.Body).Decode(&wakePayload)
+	defer r.Body.Close()
+
+	if err != nil {
+		return nil, badRequestError{errors.New("invalid body")}
+	}
+
+	err = wakePayload.Validate()
+	if err != nil {
+		return nil, badRequestError{fmt.Errorf("invalid body, error: %v", err)}
+	}
+
+	return &wakePayload, nil
+}
+
+func parseHaltPayload(r *http.Request) (*ApiHaltPayload, error) {
+	allowedJsonMimeTypes := []string{"application/json"}
+
+	contentTypeHeaderValue := r.Header.Get("Content-Type")
+	mimeType, _, err := mime.ParseMediaType(contentTypeHeaderValue)
+	if err != nil || !slices.Contains(allowedJsonMimeTypes, mimeType) {
+		return nil, badRequestError{errors.New("invalid content-type")}
+	}
+
+	var haltPayload ApiHaltPayload
+	err = json.NewDecoder(r.Body).Decode(&haltPayload)
+	defer r.Body.Close()
+
+	if err != nil {
+		return nil, badRequestError{errors.New("invalid body")}
+	}
+
+	err = haltPayload.Validate()
+	if err != nil {
+		return nil, badRequestError{fmt.Errorf("invalid body, error: %v", err)}
+	}
+
+	return &haltPayload, nil
+}
+
+func parseTrustHostKeyPayload(r *http.Request) (*ApiTrustHostKeyPayload, error) {
+	allowedJsonMimeTypes := []string{"application/json"}
+
+	contentTypeHeaderValue := r.Header.Get("Content-Type")
+	mimeType, _, err := mime.ParseMediaType(contentTypeHeaderValue)
+	if err != nil || !slices.Contains(allowedJsonMimeTypes, mimeType) {
+		return nil, badRequestError{errors.New("invalid content-type")}
+	}
+
+	var trustPayload ApiTrustHostKeyPayload
+	err = json.NewDecoder(r.Body).Decode(&trustPayload)
+	defer r.Body.Close()
+
+	if err != nil {
+		return nil, badRequestError{errors.New("invalid body")}
+	}
+
+	err = trustPayload.Validate()
+	if err != nil {
+		return nil, badRequestError{fmt.Errorf("invalid body, error: %v", err)}
+	}
+
+	return &trustPayload, nil
+}
+
+func parseLoginPayload(r *http.Request) (*ApiLoginPayload, error) {
+	allowedJsonMimeTypes := []string{"application/json"}
+
+	contentTypeHeaderValue := r.Header.Get("Content-Type")
+	mimeType, _, err := mime.ParseMediaType(contentTypeHeaderValue)
+	if err != nil || !slices.Contains(allowedJsonMimeTypes, mimeType) {
+		return nil, badRequestError{errors.New("invalid content-type")}
+	}
+
+	var loginPayload ApiLoginPayload
+	err = json.NewDecoder(r.Body).Decode(&loginPayload)
+	defer r.Body.Close()
+
+	if err != nil {
+		return nil, badRequestError{errors.New("invalid body")}
+	}
+
+	return &loginPayload, nil
+}
+
+func parsePortQueryParam(r *http.Request) (*int, error) {
+	val := r.URL.Query().Get("port")
+	if len(val) == 0 {
+		return nil, nil
+	}
+
+	port, err := strconv.Atoi(val)
+	if err != nil || port <= 0 || port > 65535 {
+		return nil, badRequestError{errors.New("invalid param port")}
+	}
+
+	return &port, nil
+}
+
+// parseAsyncParam reports whether action should run as job.
+func parseAsyncParam(r *http.Request) bool {
+	async, _ := strconv.ParseBool(r.URL.Query().Get("async"))
+	return async
+}
+
+func requirePathParam(r *http.Request, name string) (string, error) {
+	params := mux.Vars(r)
+	if uid, ok := params[name]; ok {
+		return uid, nil
+	}
+
+	return "", badRequestError{fmt.Errorf("invalid param `%s`", name)}
+}
+
+func requireQueryParam(r *http.Request, name string) (string, error) {
+	val := r.URL.Query().Get(name)
+	if len(val) > 0 {
+		return val, nil
+	}
+
+	return "", badRequestError{fmt.Errorf("invalid param %s", name)}
+}
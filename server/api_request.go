@@ -0,0 +1,133 @@
+package server
+
+import "golang.org/x/net/websocket"
+
+type route struct {
+	Name        string
+	Method      string
+	Pattern     string
+	HandlerFunc RequestProcessor
+}
+
+type wsRoute struct {
+	Name        string
+	Path        string
+	HandlerFunc func(*websocket.Conn)
+}
+
+func (h *httpApiHandler) getRoutes() []route {
+	return []route{
+		{
+			"wake",
+			"POST",
+			"/wake",
+			h.Wake,
+		},
+		{
+			"halt",
+			"POST",
+			"/halt",
+			h.Halt,
+		},
+		{
+			"status",
+			"GET",
+			"/status/{host}",
+			h.Status,
+		},
+		{
+			"targets",
+			"GET",
+			"/targets",
+			h.Targets,
+		},
+		{
+			"target_wake",
+			"POST",
+			"/targets/{id}/wake",
+			h.WakeTarget,
+		},
+		{
+			"target_halt",
+			"POST",
+			"/targets/{id}/halt",
+			h.HaltTarget,
+		},
+		{
+			"target_reboot",
+			"POST",
+			"/targets/{id}/reboot",
+			h.RebootTarget,
+		},
+		{
+			"exec",
+			"POST",
+			"/targets/{id}/exec/{name}",
+			h.Exec,
+		},
+		{
+			"jobs",
+			"GET",
+			"/jobs",
+			h.Jobs,
+		},
+		{
+			"job",
+			"GET",
+			"/jobs/{id}",
+			h.Job,
+		},
+		{
+			"cancel_job",
+			"DELETE",
+			"/jobs/{id}",
+			h.CancelJob,
+		},
+		{
+			"known_host",
+			"GET",
+			"/known-hosts/{host}",
+			h.KnownHost,
+		},
+		{
+			"trust_known_host",
+			"POST",
+			"/known-hosts",
+			h.TrustKnownHost,
+		},
+		{
+			"remove_known_host",
+			"DELETE",
+			"/known-hosts/{host}",
+			h.RemoveKnownHost,
+		},
+		{
+			"login",
+			"POST",
+			"/session",
+			h.Login,
+		},
+		{
+			"logout",
+			"DELETE",
+			"/session",
+			h.Logout,
+		},
+		{
+			"version",
+			"GET",
+			"/version",
+			h.Version,
+		},
+	}
+}
+
+func (h *httpApiHandler) getWSRoutes() []wsRoute {
+	return []wsRoute{
+		{
+			"status_stream",
+			"/status-stream",
+			h.StatusStream,
+		},
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"homecontroller/events"
+)
+
+const sseKeepAliveInterval = 15 * time.Second
+
+type eventFilter struct {
+	targets []string
+	types   []string
+}
+
+func parseEventFilter(r *http.Request) eventFilter {
+	query := r.URL.Query()
+	return eventFilter{
+		targets: splitQueryValues(query["target"]),
+		types:   splitQueryValues(query["type"]),
+	}
+}
+
+func (f eventFilter) matches(event events.Event) bool {
+	if len(f.targets) > 0 && !slices.Contains(f.targets, event.Target) {
+		return false
+	}
+
+	if len(f.types) > 0 && !slices.Contains(f.types, event.Type) {
+		return false
+	}
+
+	return true
+}
+
+// splitQueryValues accepts both repeated and comma separated query values.
+func splitQueryValues(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+
+	return result
+}
+
+func parseLastEventId(r *http.Request) (*uint64, error) {
+	rawId := r.Header.Get("Last-Event-ID")
+	if rawId == "" {
+		rawId = r.URL.Query().Get("last_event_id")
+	}
+
+	if rawId == "" {
+		return nil, nil
+	}
+
+	id, err := strconv.ParseUint(rawId, 10, 64)
+	if err != nil {
+		return nil, badRequestError{errors.New("invalid Last-Event-ID")}
+	}
+
+	return &id, nil
+}
+
+// EventStream streams events as text/event-stream. Without Last-Event-ID
+// only events published after connecting are sent.
+func (h *httpApiHandler) EventStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	lastId, err := parseLastEventId(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var missed []events.Event
+	var ch <-chan events.Event
+	var unsubscribe func()
+	if lastId != nil {
+		missed, ch, unsubscribe = events.SubscribeSince(*lastId)
+	} else {
+		ch, unsubscribe = events.Subscribe()
+	}
+	defer unsubscribe()
+
+	filter := parseEventFilter(r)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for _, event := range missed {
+		if filter.matches(event) {
+			if err := writeServerSentEvent(w, event); err != nil {
+				return
+			}
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			if !filter.matches(event) {
+				continue
+			}
+
+			if err := writeServerSentEvent(w, event); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func writeServerSentEvent(w http.ResponseWriter, event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Warning(err)
+		return nil
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data)
+	return err
+}
@@ -0,0 +1,331 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"homecontroller/config"
+	"homecontroller/controller"
+	"homecontroller/events"
+	"homecontroller/metrics"
+	"homecontroller/probing"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	wsProtocolVersion    = 1
+	wsHeartbeatInterval  = 15 * time.Second
+	wsMaxIncomingMessage = 64 * 1024
+)
+
+// Client message types
+const (
+	wsTypeSubscribe   = "subscribe"
+	wsTypeUnsubscribe = "unsubscribe"
+	wsTypeWake        = "wake"
+	wsTypeHalt        = "halt"
+	wsTypeReboot      = "reboot"
+)
+
+// Server message types
+const (
+	wsTypeHello      = "hello"
+	wsTypeSubscribed = "subscribed"
+	wsTypeStatus     = "status"
+	wsTypeEvent      = "event"
+	wsTypeResult     = "result"
+	wsTypeHeartbeat  = "heartbeat"
+	wsTypeError      = "error"
+)
+
+type wsMessage struct {
+	Version int                     `json:"v"`
+	Type    string                  `json:"type"`
+	Id      string                  `json:"id,omitempty"`
+	Target  string                  `json:"target,omitempty"`
+	Targets []string                `json:"targets,omitempty"`
+	Status  *probing.Status         `json:"status,omitempty"`
+	Event   *events.Event           `json:"event,omitempty"`
+	Error   *responseError          `json:"error,omitempty"`
+	Hooks   []controller.HookResult `json:"hooks,omitempty"`
+}
+
+func (h *httpApiHandler) StatusStream(conn *websocket.Conn) {
+	metrics.StreamClientConnected()
+	defer metrics.StreamClientDisconnected()
+
+	// clients connecting with ?host= use original single host stream
+	if host := conn.Request().URL.Query().Get("host"); host != "" {
+		h.singleHostStatusStream(conn, host)
+		return
+	}
+
+	session := &wsSession{
+		h:             h,
+		conn:          conn,
+		subscriptions: make(map[string]func()),
+	}
+	session.run()
+}
+
+func (h *httpApiHandler) singleHostStatusStream(conn *websocket.Conn, host string) {
+	updates, unsubscribe := probing.DefaultHub.Subscribe(host, probing.Config{})
+
+	go func() {
+		var msg = make([]byte, 512)
+		for {
+			if _, err := conn.Read(msg); err != nil {
+				unsubscribe()
+				return
+			}
+		}
+	}()
+
+	for status := range updates {
+		b, err := json.Marshal(status)
+		if err != nil {
+			log.Warning(err)
+			continue
+		}
+
+		_, err = conn.Write(b)
+		if err != nil {
+			unsubscribe()
+		}
+	}
+}
+
+// wsSession serves one websocket connection speaking the JSON protocol.
+// Client may subscribe to status of multiple targets and issue commands,
+// each client message may carry id which is repeated in the response.
+type wsSession struct {
+	h    *httpApiHandler
+	conn *websocket.Conn
+
+	// ctx is cancelled when client disconnects, commands of client stop then
+	ctx context.Context
+
+	writeMu sync.Mutex
+
+	mu            sync.Mutex
+	subscriptions map[string]func()
+}
+
+func (s *wsSession) run() {
+	s.conn.MaxPayloadBytes = wsMaxIncomingMessage
+	defer s.unsubscribeAll()
+
+	eventsCh, unsubscribeEvents := events.Subscribe()
+	defer unsubscribeEvents()
+
+	ctx, cancel := context.WithCancel(s.conn.Request().Context())
+	defer cancel()
+	s.ctx = ctx
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go s.forwardEvents(eventsCh, done)
+	go s.sendHeartbeats(done)
+
+	s.send(wsMessage{Type: wsTypeHello})
+
+	for {
+		var data []byte
+		err := websocket.Message.Receive(s.conn, &data)
+		if err != nil {
+			return
+		}
+
+		var msg wsMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			s.sendError("", badRequestError{errors.New("invalid message")})
+			continue
+		}
+
+		s.handleMessage(msg)
+	}
+}
+
+func (s *wsSession) handleMessage(msg wsMessage) {
+	if msg.Version != wsProtocolVersion {
+		s.sendError(msg.Id, badRequestError{fmt.Errorf("unsupported protocol version %d", msg.Version)})
+		return
+	}
+
+	switch msg.Type {
+	case wsTypeSubscribe:
+		for _, target := range msg.Targets {
+			s.subscribe(target)
+		}
+		s.send(wsMessage{Type: wsTypeSubscribed, Id: msg.Id, Targets: s.subscribedTargets()})
+	case wsTypeUnsubscribe:
+		for _, target := range msg.Targets {
+			s.unsubscribe(target)
+		}
+		s.send(wsMessage{Type: wsTypeSubscribed, Id: msg.Id, Targets: s.subscribedTargets()})
+	case wsTypeWake, wsTypeHalt, wsTypeReboot:
+		target := s.h.getTarget(msg.Target)
+		if target == nil {
+			s.sendError(msg.Id, badRequestError{fmt.Errorf("unknown target '%s'", msg.Target)})
+			return
+		}
+
+		go s.runCommand(msg, target)
+	default:
+		s.sendError(msg.Id, badRequestError{fmt.Errorf("unknown message type '%s'", msg.Type)})
+	}
+}
+
+func (s *wsSession) runCommand(msg wsMessage, target *config.TargetConfiguration) {
+	var result controller.ActionResult
+	var err error
+	switch msg.Type {
+	case wsTypeWake:
+		result, err = controller.Wake(s.ctx, target, nil)
+	case wsTypeHalt:
+		result, err = controller.Halt(s.ctx, target, nil)
+	case wsTypeReboot:
+		result, err = controller.Reboot(s.ctx, target, nil)
+	}
+
+	if err != nil {
+		reply := errorMessage(msg.Id, actionHttpError(err))
+		reply.Target = target.Id
+		reply.Hooks = result.Hooks
+		s.send(reply)
+		return
+	}
+
+	s.send(wsMessage{Type: wsTypeResult, Id: msg.Id, Target: target.Id, Hooks: result.Hooks})
+}
+
+// resolveProbe returns host and probe of registered target, or the target
+// itself with default probe, so that any host can be observed.
+func (s *wsSession) resolveProbe(target string) (string, probing.Config) {
+	if registered := s.h.getTarget(target); registered != nil {
+		return registered.Host, registered.ProbeConfig()
+	}
+
+	return target, probing.Config{}
+}
+
+func (s *wsSession) subscribe(target string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subscriptions[target]; ok {
+		return
+	}
+
+	updates, unsubscribe := probing.DefaultHub.Subscribe(s.resolveProbe(target))
+	s.subscriptions[target] = unsubscribe
+
+	go func() {
+		var last *probing.Status
+		for status := range updates {
+			if last != nil && *last == status {
+				continue
+			}
+			last = &status
+			s.send(wsMessage{Type: wsTypeStatus, Target: target, Status: &status})
+		}
+	}()
+}
+
+func (s *wsSession) unsubscribe(target string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if unsubscribe, ok := s.subscriptions[target]; ok {
+		unsubscribe()
+		delete(s.subscriptions, target)
+	}
+}
+
+func (s *wsSession) unsubscribeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for target, unsubscribe := range s.subscriptions {
+		unsubscribe()
+		delete(s.subscriptions, target)
+	}
+}
+
+func (s *wsSession) isSubscribed(target string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.subscriptions[target]
+	return ok
+}
+
+func (s *wsSession) subscribedTargets() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	targets := make([]string, 0, len(s.subscriptions))
+	for target := range s.subscriptions {
+		targets = append(targets, target)
+	}
+
+	return targets
+}
+
+func (s *wsSession) forwardEvents(ch <-chan events.Event, done chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			if s.isSubscribed(event.Target) {
+				s.send(wsMessage{Type: wsTypeEvent, Target: event.Target, Event: &event})
+			}
+		}
+	}
+}
+
+func (s *wsSession) sendHeartbeats(done chan struct{}) {
+	ticker := time.NewTicker(wsHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			s.send(wsMessage{Type: wsTypeHeartbeat})
+		}
+	}
+}
+
+func (s *wsSession) sendError(id string, err error) {
+	s.send(errorMessage(id, err))
+}
+
+// errorMessage reports err the same way as error response of HTTP API.
+func errorMessage(id string, err error) wsMessage {
+	errObj := newResponseError(err)
+	return wsMessage{Type: wsTypeError, Id: id, Error: &errObj}
+}
+
+func (s *wsSession) send(msg wsMessage) {
+	msg.Version = wsProtocolVersion
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := websocket.JSON.Send(s.conn, msg); err != nil {
+		log.Debugf("Could not write to websocket: %v", err)
+	}
+}
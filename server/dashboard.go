@@ -0,0 +1,30 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// EnableDashboard serves web dashboard under /dashboard/. Dashboard uses the
+// API itself, browser logs in with auth token and keeps session cookie.
+func (h *httpApiHandler) EnableDashboard() {
+	files, err := fs.Sub(dashboardFiles, "dashboard")
+	if err != nil {
+		log.Errorf("Could not load dashboard: %v", err)
+		return
+	}
+
+	h.router.Methods("GET").
+		Path("/").
+		Name("root").
+		Handler(http.RedirectHandler("/dashboard/", http.StatusFound))
+
+	h.router.Methods("GET").
+		PathPrefix("/dashboard/").
+		Name("dashboard").
+		Handler(http.StripPrefix("/dashboard/", http.FileServer(http.FS(files))))
+}
@@ -0,0 +1,254 @@
+"use strict";
+
+// Dashboard uses the same API as other clients. Requests carry session cookie
+// set by POST /session, any 403 response shows login form again.
+
+const historyTypes = [
+  "action.wake", "action.halt", "action.reboot", "action.exec",
+  "wake.verified", "wake.timeout",
+];
+const historySize = 20;
+const actions = ["wake", "halt", "reboot"];
+
+const state = {
+  targets: [],
+  status: {},
+  socket: null,
+  eventSource: null,
+};
+
+const el = (id) => document.getElementById(id);
+
+class ForbiddenError extends Error {}
+
+async function api(method, path, body) {
+  const options = { method, credentials: "same-origin", headers: {} };
+  if (body !== undefined) {
+    options.headers["Content-Type"] = "application/json";
+    options.body = JSON.stringify(body);
+  }
+
+  const response = await fetch(path, options);
+  if (response.status === 403) {
+    throw new ForbiddenError("forbidden");
+  }
+
+  const text = await response.text();
+  const data = text ? JSON.parse(text) : null;
+  if (!response.ok) {
+    throw new Error((data && data.message) || response.statusText);
+  }
+
+  return data;
+}
+
+function showLogin() {
+  disconnect();
+  el("dashboard").hidden = true;
+  el("logout").hidden = true;
+  el("login").hidden = false;
+  el("token").focus();
+}
+
+function showDashboard() {
+  el("login").hidden = true;
+  el("dashboard").hidden = false;
+  el("logout").hidden = false;
+}
+
+async function start() {
+  try {
+    state.targets = await api("GET", "/targets");
+  } catch (err) {
+    if (err instanceof ForbiddenError) {
+      showLogin();
+      return;
+    }
+    el("connection").textContent = "Could not load targets: " + err.message;
+    showDashboard();
+    return;
+  }
+
+  showDashboard();
+  renderTargets();
+  connectStatus();
+  connectHistory();
+}
+
+function renderTargets() {
+  const body = el("targets");
+  body.replaceChildren();
+
+  for (const target of state.targets) {
+    const row = document.createElement("tr");
+    row.id = "target-" + target.id;
+
+    const name = document.createElement("td");
+    name.textContent = target.id;
+    name.title = target.host;
+
+    const status = document.createElement("td");
+    status.className = "status";
+    status.textContent = "unknown";
+
+    const buttons = document.createElement("td");
+    buttons.className = "actions";
+    for (const action of actions) {
+      const button = document.createElement("button");
+      button.textContent = action;
+      button.addEventListener("click", () => runAction(target, action, button));
+      buttons.append(button);
+    }
+
+    row.append(name, status, buttons);
+    body.append(row);
+    renderStatus(target.id);
+  }
+}
+
+function renderStatus(id) {
+  const row = el("target-" + id);
+  if (!row || !(id in state.status)) {
+    return;
+  }
+
+  const online = state.status[id];
+  const cell = row.querySelector(".status");
+  cell.classList.toggle("online", online);
+  cell.classList.toggle("offline", !online);
+  cell.textContent = online ? "online" : "offline";
+}
+
+async function runAction(target, action, button) {
+  if (!confirm(`Really ${action} ${target.id}?`)) {
+    return;
+  }
+
+  el("action-error").textContent = "";
+  button.disabled = true;
+  try {
+    // async only starts job, result shows up in history
+    await api("POST", `/targets/${encodeURIComponent(target.id)}/${action}?async=1`);
+  } catch (err) {
+    if (err instanceof ForbiddenError) {
+      showLogin();
+      return;
+    }
+    el("action-error").textContent = `${action} of ${target.id} failed: ${err.message}`;
+  } finally {
+    button.disabled = false;
+  }
+}
+
+function connectStatus() {
+  const scheme = location.protocol === "https:" ? "wss:" : "ws:";
+  const socket = new WebSocket(`${scheme}//${location.host}/status-stream`);
+  state.socket = socket;
+
+  socket.addEventListener("open", () => {
+    el("connection").textContent = "";
+    socket.send(JSON.stringify({
+      v: 1,
+      type: "subscribe",
+      targets: state.targets.map((target) => target.id),
+    }));
+  });
+
+  socket.addEventListener("message", (message) => {
+    const msg = JSON.parse(message.data);
+    if (msg.type === "status" && msg.status) {
+      state.status[msg.target] = msg.status.is_online;
+      renderStatus(msg.target);
+    }
+  });
+
+  socket.addEventListener("close", () => {
+    if (state.socket !== socket) {
+      return;
+    }
+    el("connection").textContent = "Connection lost, reconnecting…";
+    setTimeout(() => {
+      if (state.socket === socket) {
+        connectStatus();
+      }
+    }, 3000);
+  });
+}
+
+function connectHistory() {
+  // replays retained events, browser resumes from last id on reconnect
+  const source = new EventSource("/events?last_event_id=0&type=" + historyTypes.join(","));
+  state.eventSource = source;
+
+  for (const type of historyTypes) {
+    source.addEventListener(type, (message) => addHistory(JSON.parse(message.data)));
+  }
+}
+
+function addHistory(event) {
+  const list = el("history");
+  const item = document.createElement("li");
+  const time = new Date(event.time).toLocaleString();
+  const error = event.data && event.data.error;
+
+  item.textContent = `${time}: ${describeEvent(event.type)} ${event.target}`;
+  if (error) {
+    item.className = "failed";
+    item.textContent += ` failed: ${error}`;
+  }
+
+  list.prepend(item);
+  while (list.children.length > historySize) {
+    list.lastChild.remove();
+  }
+}
+
+function describeEvent(type) {
+  switch (type) {
+    case "wake.verified":
+      return "came online after wake:";
+    case "wake.timeout":
+      return "did not come online after wake:";
+    default:
+      return type.replace("action.", "");
+  }
+}
+
+function disconnect() {
+  if (state.socket) {
+    const socket = state.socket;
+    state.socket = null;
+    socket.close();
+  }
+  if (state.eventSource) {
+    state.eventSource.close();
+    state.eventSource = null;
+  }
+  el("history").replaceChildren();
+  state.status = {};
+}
+
+el("login").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  el("login-error").textContent = "";
+
+  try {
+    await api("POST", "/session", { token: el("token").value });
+  } catch (err) {
+    el("login-error").textContent = err instanceof ForbiddenError ? "Login failed" : err.message;
+    return;
+  }
+
+  el("token").value = "";
+  start();
+});
+
+el("logout").addEventListener("click", async () => {
+  try {
+    await api("DELETE", "/session");
+  } finally {
+    showLogin();
+  }
+});
+
+start();
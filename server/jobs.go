@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"homecontroller/controller"
+)
+
+const (
+	JobStatePending   = "pending"
+	JobStateRunning   = "running"
+	JobStateSucceeded = "succeeded"
+	JobStateFailed    = "failed"
+	JobStateCancelled = "cancelled"
+)
+
+const DefaultJobRetention = time.Hour
+
+type job struct {
+	mu     sync.Mutex
+	data   ApiJobData
+	cancel context.CancelFunc
+}
+
+func (j *job) snapshot() ApiJobData {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.data
+}
+
+func (j *job) update(fn func(data *ApiJobData)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	fn(&j.data)
+}
+
+func (j *job) finished() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.data.Finished != nil
+}
+
+// jobManager keeps jobs until retention passes after they finish.
+type jobManager struct {
+	mu        sync.Mutex
+	retention time.Duration
+	jobs      map[string]*job
+
+	cleanupOnce sync.Once
+}
+
+func newJobManager(retention time.Duration) *jobManager {
+	return &jobManager{
+		retention: retention,
+		jobs:      make(map[string]*job),
+	}
+}
+
+func (m *jobManager) SetRetention(retention time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.retention = retention
+}
+
+// Start runs fn in background as job. Result of fn is stored as job result,
+// error fails the job unless it was caused by cancellation.
+func (m *jobManager) Start(action string, target string, fn func(ctx context.Context) (interface{}, error)) ApiJobData {
+	m.cleanupOnce.Do(func() {
+		go m.runCleanup()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		data: ApiJobData{
+			Id:      newJobId(),
+			Action:  action,
+			Target:  target,
+			State:   JobStatePending,
+			Created: time.Now(),
+		},
+		cancel: cancel,
+	}
+
+	m.mu.Lock()
+	m.jobs[j.data.Id] = j
+	m.mu.Unlock()
+
+	ctx = controller.WithProgress(ctx, func(progress string) {
+		j.update(func(data *ApiJobData) {
+			data.Progress = progress
+		})
+	})
+
+	go func() {
+		defer cancel()
+
+		j.update(func(data *ApiJobData) {
+			data.State = JobStateRunning
+		})
+
+		result, err := fn(ctx)
+
+		var raw json.RawMessage
+		if result != nil {
+			raw, _ = json.Marshal(result)
+		}
+
+		j.update(func(data *ApiJobData) {
+			now := time.Now()
+			data.Finished = &now
+			data.Result = raw
+
+			switch {
+			case err == nil:
+				data.State = JobStateSucceeded
+			case ctx.Err() != nil:
+				data.State = JobStateCancelled
+			default:
+				data.State = JobStateFailed
+				respErr := newResponseError(err)
+				data.Error = &respErr
+			}
+		})
+
+		data := j.snapshot()
+		log.Infof("Job %s (%s of %s) %s", data.Id, data.Action, data.Target, data.State)
+	}()
+
+	return j.snapshot()
+}
+
+func (m *jobManager) Get(id string) (ApiJobData, bool) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	m.mu.Unlock()
+
+	if !ok {
+		return ApiJobData{}, false
+	}
+
+	return j.snapshot(), true
+}
+
+// List returns all retained jobs, newest first.
+func (m *jobManager) List() []ApiJobData {
+	m.mu.Lock()
+	list := make([]ApiJobData, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		list = append(list, j.snapshot())
+	}
+	m.mu.Unlock()
+
+	sort.Slice(list, func(a, b int) bool {
+		return list[a].Created.After(list[b].Created)
+	})
+
+	return list
+}
+
+// Cancel requests cancellation of job, actions stop at the next step which
+// can be interrupted.
+func (m *jobManager) Cancel(id string) (ApiJobData, bool) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	m.mu.Unlock()
+
+	if !ok {
+		return ApiJobData{}, false
+	}
+
+	if !j.finished() {
+		j.cancel()
+		j.update(func(data *ApiJobData) {
+			data.Progress = "cancelling"
+		})
+	}
+
+	return j.snapshot(), true
+}
+
+func (m *jobManager) runCleanup() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		m.removeExpired()
+	}
+}
+
+func (m *jobManager) removeExpired() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, j := range m.jobs {
+		data := j.snapshot()
+		if data.Finished != nil && time.Since(*data.Finished) > m.retention {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+func newJobId() string {
+	bts := make([]byte, 8)
+	if _, err := rand.Read(bts); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(bts)
+}
@@ -0,0 +1,355 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"homecontroller/config"
+	"homecontroller/controller"
+	"homecontroller/probing"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	mqttPayloadOn      = "ON"
+	mqttPayloadOff     = "OFF"
+	mqttPayloadOnline  = "online"
+	mqttPayloadOffline = "offline"
+)
+
+type MqttOptions struct {
+	Broker          string
+	ClientId        string
+	User            string
+	Password        string
+	TopicPrefix     string
+	DiscoveryPrefix string
+}
+
+// mqttWake and mqttHalt run commands received from the broker, replaceable
+// by fakes in tests.
+var (
+	mqttWake = controller.Wake
+	mqttHalt = controller.Halt
+)
+
+// mqttConnection is the subset of broker operations used by the bridge, so
+// that the bridge can run against a fake broker.
+type mqttConnection interface {
+	Publish(topic string, payload []byte, retained bool) error
+	Subscribe(topic string, handler func(topic string, payload []byte)) error
+	Unsubscribe(topic string) error
+	Close()
+}
+
+// MqttBridge publishes registered targets to Home Assistant via MQTT
+// discovery and executes wake/halt on commands received from the broker.
+type MqttBridge struct {
+	opts    MqttOptions
+	monitor *controller.StatusMonitor
+	conn    mqttConnection
+
+	mu      sync.Mutex
+	targets []config.TargetConfiguration
+}
+
+type mqttDiscoveryDevice struct {
+	Identifiers []string `json:"identifiers"`
+	Name        string   `json:"name"`
+}
+
+type mqttDiscoveryConfig struct {
+	Name              string              `json:"name"`
+	UniqueId          string              `json:"unique_id"`
+	StateTopic        string              `json:"state_topic"`
+	CommandTopic      string              `json:"command_topic,omitempty"`
+	AvailabilityTopic string              `json:"availability_topic"`
+	PayloadOn         string              `json:"payload_on"`
+	PayloadOff        string              `json:"payload_off"`
+	DeviceClass       string              `json:"device_class,omitempty"`
+	Device            mqttDiscoveryDevice `json:"device"`
+}
+
+func NewMqttBridge(opts MqttOptions, targets []config.TargetConfiguration, monitor *controller.StatusMonitor) *MqttBridge {
+	return &MqttBridge{
+		opts:    opts,
+		targets: targets,
+		monitor: monitor,
+	}
+}
+
+func (b *MqttBridge) availabilityTopic() string {
+	return fmt.Sprintf("%s/availability", b.opts.TopicPrefix)
+}
+
+func (b *MqttBridge) stateTopic(target *config.TargetConfiguration) string {
+	return fmt.Sprintf("%s/%s/state", b.opts.TopicPrefix, target.Id)
+}
+
+func (b *MqttBridge) commandTopic(target *config.TargetConfiguration) string {
+	return fmt.Sprintf("%s/%s/set", b.opts.TopicPrefix, target.Id)
+}
+
+func (b *MqttBridge) discoveryTopic(component string, target *config.TargetConfiguration) string {
+	return fmt.Sprintf("%s/%s/%s_%s/config", b.opts.DiscoveryPrefix, component, b.opts.ClientId, target.Id)
+}
+
+// Start connects to the broker and starts forwarding status changes.
+func (b *MqttBridge) Start() error {
+	conn, err := dialPahoMqtt(b.opts, b.availabilityTopic(), b.handleConnect)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.conn = conn
+	b.mu.Unlock()
+
+	b.monitor.OnChange(b.publishState)
+	return nil
+}
+
+func (b *MqttBridge) Stop() {
+	b.mu.Lock()
+	conn := b.conn
+	b.mu.Unlock()
+
+	if conn == nil {
+		return
+	}
+
+	if err := conn.Publish(b.availabilityTopic(), []byte(mqttPayloadOffline), true); err != nil {
+		log.Warningf("MQTT: could not publish availability: %v", err)
+	}
+	conn.Close()
+}
+
+// handleConnect is called on every (re)connect to the broker; it announces
+// all targets and restores command subscriptions.
+func (b *MqttBridge) handleConnect(conn mqttConnection) {
+	b.mu.Lock()
+	targets := b.targets
+	b.mu.Unlock()
+
+	for i := range targets {
+		b.announce(conn, &targets[i])
+	}
+
+	if err := conn.Publish(b.availabilityTopic(), []byte(mqttPayloadOnline), true); err != nil {
+		log.Errorf("MQTT: could not publish availability: %v", err)
+	}
+}
+
+// SetTargets replaces published targets, added and changed targets are
+// announced and removed targets are withdrawn from Home Assistant.
+func (b *MqttBridge) SetTargets(targets []config.TargetConfiguration) {
+	b.mu.Lock()
+	previous := b.targets
+	b.targets = targets
+	conn := b.conn
+	b.mu.Unlock()
+
+	if conn == nil {
+		return
+	}
+
+	for i := range targets {
+		target := &targets[i]
+		if old := config.TargetById(previous, target.Id); old == nil || !reflect.DeepEqual(*old, *target) {
+			b.announce(conn, target)
+		}
+	}
+
+	for i := range previous {
+		if config.TargetById(targets, previous[i].Id) == nil {
+			b.withdraw(conn, &previous[i])
+		}
+	}
+}
+
+func (b *MqttBridge) announce(conn mqttConnection, target *config.TargetConfiguration) {
+	if err := b.publishDiscovery(conn, target); err != nil {
+		log.Errorf("MQTT: could not publish discovery of target %s: %v", target.Id, err)
+	}
+
+	err := conn.Subscribe(b.commandTopic(target), func(topic string, payload []byte) {
+		go b.handleCommand(target, string(payload))
+	})
+	if err != nil {
+		log.Errorf("MQTT: could not subscribe to commands of target %s: %v", target.Id, err)
+	}
+
+	if status, ok := b.monitor.Status(target.Id); ok {
+		b.publishStateOn(conn, target, status)
+	}
+}
+
+// withdraw removes target from Home Assistant by publishing empty retained
+// discovery configs.
+func (b *MqttBridge) withdraw(conn mqttConnection, target *config.TargetConfiguration) {
+	if err := conn.Unsubscribe(b.commandTopic(target)); err != nil {
+		log.Errorf("MQTT: could not unsubscribe from commands of target %s: %v", target.Id, err)
+	}
+
+	for _, topic := range []string{
+		b.discoveryTopic("switch", target),
+		b.discoveryTopic("binary_sensor", target),
+		b.stateTopic(target),
+	} {
+		if err := conn.Publish(topic, []byte{}, true); err != nil {
+			log.Errorf("MQTT: could not withdraw target %s: %v", target.Id, err)
+		}
+	}
+}
+
+func (b *MqttBridge) publishDiscovery(conn mqttConnection, target *config.TargetConfiguration) error {
+	device := mqttDiscoveryDevice{
+		Identifiers: []string{fmt.Sprintf("%s_%s", b.opts.ClientId, target.Id)},
+		Name:        target.Id,
+	}
+
+	configs := map[string]mqttDiscoveryConfig{
+		"switch": {
+			Name:              fmt.Sprintf("%s power", target.Id),
+			UniqueId:          fmt.Sprintf("%s_%s_power", b.opts.ClientId, target.Id),
+			StateTopic:        b.stateTopic(target),
+			CommandTopic:      b.commandTopic(target),
+			AvailabilityTopic: b.availabilityTopic(),
+			PayloadOn:         mqttPayloadOn,
+			PayloadOff:        mqttPayloadOff,
+			Device:            device,
+		},
+		"binary_sensor": {
+			Name:              fmt.Sprintf("%s online", target.Id),
+			UniqueId:          fmt.Sprintf("%s_%s_online", b.opts.ClientId, target.Id),
+			StateTopic:        b.stateTopic(target),
+			AvailabilityTopic: b.availabilityTopic(),
+			PayloadOn:         mqttPayloadOn,
+			PayloadOff:        mqttPayloadOff,
+			DeviceClass:       "connectivity",
+			Device:            device,
+		},
+	}
+
+	for component, config := range configs {
+		bts, err := json.Marshal(config)
+		if err != nil {
+			return err
+		}
+
+		err = conn.Publish(b.discoveryTopic(component, target), bts, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *MqttBridge) publishState(target *config.TargetConfiguration, status probing.Status) {
+	b.mu.Lock()
+	conn := b.conn
+	b.mu.Unlock()
+
+	if conn != nil {
+		b.publishStateOn(conn, target, status)
+	}
+}
+
+func (b *MqttBridge) publishStateOn(conn mqttConnection, target *config.TargetConfiguration, status probing.Status) {
+	payload := mqttPayloadOff
+	if status.IsOnline {
+		payload = mqttPayloadOn
+	}
+
+	if err := conn.Publish(b.stateTopic(target), []byte(payload), true); err != nil {
+		log.Errorf("MQTT: could not publish state of target %s: %v", target.Id, err)
+	}
+}
+
+func (b *MqttBridge) handleCommand(target *config.TargetConfiguration, payload string) {
+	var err error
+	switch strings.ToUpper(strings.TrimSpace(payload)) {
+	case mqttPayloadOn:
+		log.Infof("MQTT: waking target '%s'", target.Id)
+		_, err = mqttWake(context.Background(), target, nil)
+	case mqttPayloadOff:
+		log.Infof("MQTT: halting target '%s'", target.Id)
+		_, err = mqttHalt(context.Background(), target, nil)
+	default:
+		log.Warningf("MQTT: unknown command '%s' for target %s", payload, target.Id)
+		return
+	}
+
+	if err != nil {
+		log.Errorf("MQTT: command '%s' failed for target %s: %v", payload, target.Id, err)
+	}
+}
+
+type pahoMqttConnection struct {
+	client mqtt.Client
+}
+
+func dialPahoMqtt(opts MqttOptions, willTopic string, onConnect func(conn mqttConnection)) (mqttConnection, error) {
+	conn := &pahoMqttConnection{}
+
+	clientOpts := mqtt.NewClientOptions().
+		AddBroker(opts.Broker).
+		SetClientID(opts.ClientId).
+		SetUsername(opts.User).
+		SetPassword(opts.Password).
+		SetWill(willTopic, mqttPayloadOffline, 1, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOnConnectHandler(func(client mqtt.Client) {
+			log.Infof("MQTT: connected to %s", opts.Broker)
+			// handler runs synchronously in paho, publishing must not block it
+			go onConnect(conn)
+		}).
+		SetConnectionLostHandler(func(client mqtt.Client, err error) {
+			log.Warningf("MQTT: connection lost: %v", err)
+		})
+
+	conn.client = mqtt.NewClient(clientOpts)
+	token := conn.client.Connect()
+	if !token.WaitTimeout(10 * time.Second) {
+		log.Warningf("MQTT: broker %s not reachable yet, retrying in background", opts.Broker)
+		return conn, nil
+	}
+
+	if err := token.Error(); err != nil {
+		return nil, fmt.Errorf("couldnt connect to mqtt broker, %s", err)
+	}
+
+	return conn, nil
+}
+
+func (c *pahoMqttConnection) Publish(topic string, payload []byte, retained bool) error {
+	token := c.client.Publish(topic, 1, retained, payload)
+	token.Wait()
+	return token.Error()
+}
+
+func (c *pahoMqttConnection) Subscribe(topic string, handler func(topic string, payload []byte)) error {
+	token := c.client.Subscribe(topic, 1, func(client mqtt.Client, msg mqtt.Message) {
+		handler(msg.Topic(), msg.Payload())
+	})
+	token.Wait()
+	return token.Error()
+}
+
+func (c *pahoMqttConnection) Unsubscribe(topic string) error {
+	token := c.client.Unsubscribe(topic)
+	token.Wait()
+	return token.Error()
+}
+
+func (c *pahoMqttConnection) Close() {
+	c.client.Disconnect(250)
+}
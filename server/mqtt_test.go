@@ -0,0 +1,271 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"homecontroller/config"
+	"homecontroller/controller"
+	"homecontroller/sshctl"
+)
+
+type fakeMqttConnection struct {
+	mu        sync.Mutex
+	retained  map[string][]byte
+	publishes map[string]int
+	handlers  map[string]func(topic string, payload []byte)
+	closed    bool
+}
+
+func newFakeMqttConnection() *fakeMqttConnection {
+	return &fakeMqttConnection{
+		retained:  make(map[string][]byte),
+		publishes: make(map[string]int),
+		handlers:  make(map[string]func(topic string, payload []byte)),
+	}
+}
+
+func (c *fakeMqttConnection) Publish(topic string, payload []byte, retained bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.publishes[topic]++
+	if retained {
+		c.retained[topic] = payload
+	}
+	return nil
+}
+
+func (c *fakeMqttConnection) Subscribe(topic string, handler func(topic string, payload []byte)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.handlers[topic] = handler
+	return nil
+}
+
+func (c *fakeMqttConnection) Unsubscribe(topic string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.handlers, topic)
+	return nil
+}
+
+func (c *fakeMqttConnection) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+}
+
+func (c *fakeMqttConnection) payload(topic string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	payload, ok := c.retained[topic]
+	return string(payload), ok
+}
+
+func (c *fakeMqttConnection) publishCount(topic string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.publishes[topic]
+}
+
+func (c *fakeMqttConnection) handler(topic string) func(topic string, payload []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.handlers[topic]
+}
+
+func newTestMqttBridge(targets []config.TargetConfiguration) *MqttBridge {
+	opts := MqttOptions{
+		ClientId:        "hc",
+		TopicPrefix:     "homecontroller",
+		DiscoveryPrefix: "homeassistant",
+	}
+	return NewMqttBridge(opts, targets, controller.NewStatusMonitor(targets))
+}
+
+func TestMqttDiscovery(t *testing.T) {
+	bridge := newTestMqttBridge([]config.TargetConfiguration{{Id: "pc", Host: "pc.lan"}})
+	conn := newFakeMqttConnection()
+	bridge.handleConnect(conn)
+
+	tests := []struct {
+		topic    string
+		expected mqttDiscoveryConfig
+	}{
+		{
+			topic: "homeassistant/switch/hc_pc/config",
+			expected: mqttDiscoveryConfig{
+				Name:              "pc power",
+				UniqueId:          "hc_pc_power",
+				StateTopic:        "homecontroller/pc/state",
+				CommandTopic:      "homecontroller/pc/set",
+				AvailabilityTopic: "homecontroller/availability",
+				PayloadOn:         "ON",
+				PayloadOff:        "OFF",
+				Device:            mqttDiscoveryDevice{Identifiers: []string{"hc_pc"}, Name: "pc"},
+			},
+		},
+		{
+			topic: "homeassistant/binary_sensor/hc_pc/config",
+			expected: mqttDiscoveryConfig{
+				Name:              "pc online",
+				UniqueId:          "hc_pc_online",
+				StateTopic:        "homecontroller/pc/state",
+				AvailabilityTopic: "homecontroller/availability",
+				PayloadOn:         "ON",
+				PayloadOff:        "OFF",
+				DeviceClass:       "connectivity",
+				Device:            mqttDiscoveryDevice{Identifiers: []string{"hc_pc"}, Name: "pc"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		payload, ok := conn.payload(test.topic)
+		if !ok {
+			t.Fatalf("discovery config was not published to %s", test.topic)
+		}
+
+		var actual mqttDiscoveryConfig
+		if err := json.Unmarshal([]byte(payload), &actual); err != nil {
+			t.Fatalf("invalid discovery config on %s: %v", test.topic, err)
+		}
+		expectedJson, _ := json.Marshal(test.expected)
+		actualJson, _ := json.Marshal(actual)
+		if string(expectedJson) != string(actualJson) {
+			t.Errorf("discovery config on %s is %s, expected %s", test.topic, actualJson, expectedJson)
+		}
+	}
+
+	if count := conn.publishCount("homeassistant/switch/hc_pc/config"); count != 1 {
+		t.Errorf("discovery config was published %d times, expected once", count)
+	}
+	if payload, _ := conn.payload("homecontroller/availability"); payload != "online" {
+		t.Errorf("availability is '%s', expected 'online'", payload)
+	}
+	if conn.handler("homecontroller/pc/set") == nil {
+		t.Error("command topic of target was not subscribed")
+	}
+}
+
+func TestMqttCommands(t *testing.T) {
+	calls := make(chan string, 1)
+	fakeAction := func(name string) func(context.Context, *config.TargetConfiguration, sshctl.PromptFunc) (controller.ActionResult, error) {
+		return func(ctx context.Context, target *config.TargetConfiguration, prompt sshctl.PromptFunc) (controller.ActionResult, error) {
+			calls <- name + " " + target.Id
+			return controller.ActionResult{Target: target.Id}, nil
+		}
+	}
+
+	defer func(wake, halt func(context.Context, *config.TargetConfiguration, sshctl.PromptFunc) (controller.ActionResult, error)) {
+		mqttWake, mqttHalt = wake, halt
+	}(mqttWake, mqttHalt)
+	mqttWake, mqttHalt = fakeAction("wake"), fakeAction("halt")
+
+	bridge := newTestMqttBridge([]config.TargetConfiguration{{Id: "pc", Host: "pc.lan"}})
+	conn := newFakeMqttConnection()
+	bridge.handleConnect(conn)
+
+	handler := conn.handler("homecontroller/pc/set")
+	if handler == nil {
+		t.Fatal("command topic of target was not subscribed")
+	}
+
+	tests := []struct {
+		payload  string
+		expected string
+	}{
+		{"ON", "wake pc"},
+		{"off", "halt pc"},
+		{" on\n", "wake pc"},
+	}
+
+	for _, test := range tests {
+		handler("homecontroller/pc/set", []byte(test.payload))
+
+		select {
+		case call := <-calls:
+			if call != test.expected {
+				t.Errorf("command '%s' ran %s, expected %s", test.payload, call, test.expected)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("command '%s' did not run %s", test.payload, test.expected)
+		}
+	}
+
+	// unknown command is handled synchronously, so nothing may be queued
+	bridge.handleCommand(&config.TargetConfiguration{Id: "pc"}, "TOGGLE")
+	select {
+	case call := <-calls:
+		t.Errorf("unknown command ran %s", call)
+	default:
+	}
+}
+
+func TestMqttSetTargets(t *testing.T) {
+	previous := []config.TargetConfiguration{
+		{Id: "pc", Host: "pc.lan"},
+		{Id: "nas", Host: "nas.lan"},
+		{Id: "old", Host: "old.lan"},
+	}
+	bridge := newTestMqttBridge(previous)
+	conn := newFakeMqttConnection()
+	bridge.conn = conn
+	bridge.handleConnect(conn)
+
+	bridge.SetTargets([]config.TargetConfiguration{
+		{Id: "pc", Host: "pc.lan"},
+		{Id: "nas", Host: "nas.home"},
+		{Id: "new", Host: "new.lan"},
+	})
+
+	if count := conn.publishCount("homeassistant/switch/hc_pc/config"); count != 1 {
+		t.Errorf("unchanged target was announced %d times, expected once", count)
+	}
+	if count := conn.publishCount("homeassistant/switch/hc_nas/config"); count != 2 {
+		t.Errorf("changed target was announced %d times, expected twice", count)
+	}
+	if _, ok := conn.payload("homeassistant/switch/hc_new/config"); !ok {
+		t.Error("added target was not announced")
+	}
+	if conn.handler("homecontroller/new/set") == nil {
+		t.Error("command topic of added target was not subscribed")
+	}
+
+	for _, topic := range []string{
+		"homeassistant/switch/hc_old/config",
+		"homeassistant/binary_sensor/hc_old/config",
+		"homecontroller/old/state",
+	} {
+		if payload, ok := conn.payload(topic); !ok || payload != "" {
+			t.Errorf("removed target was not withdrawn from %s", topic)
+		}
+	}
+	if conn.handler("homecontroller/old/set") != nil {
+		t.Error("command topic of removed target is still subscribed")
+	}
+}
+
+func TestMqttStop(t *testing.T) {
+	bridge := newTestMqttBridge(nil)
+	conn := newFakeMqttConnection()
+	bridge.conn = conn
+	bridge.Stop()
+
+	if payload, _ := conn.payload("homecontroller/availability"); payload != "offline" {
+		t.Errorf("availability is '%s', expected 'offline'", payload)
+	}
+	if !conn.closed {
+		t.Error("connection was not closed")
+	}
+}
@@ -0,0 +1,429 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"homecontroller/config"
+	"homecontroller/controller"
+	"homecontroller/probing"
+
+	"github.com/gorilla/mux"
+)
+
+// routeDoc describes route in OpenAPI document. Request and Response are
+// values of body types, nil when route has no body.
+type routeDoc struct {
+	Summary     string
+	Request     interface{}
+	Response    interface{}
+	Query       []queryParamDoc
+	ContentType string
+	Async       bool
+	WebSocket   bool
+}
+
+type queryParamDoc struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+var asyncQueryParam = queryParamDoc{"async", "boolean", "Run action as job, respond 202 with the job"}
+
+// routeDocs are keyed by route name. The document itself is built from
+// registered routes, so route without doc is still listed.
+func routeDocs() map[string]routeDoc {
+	return map[string]routeDoc{
+		"wake": {
+			Summary: "Send magic packet",
+			Request: ApiWakePayload{},
+		},
+		"halt": {
+			Summary: "Halt host via SSH",
+			Request: ApiHaltPayload{},
+		},
+		"status": {
+			Summary:  "Online status of host",
+			Response: probing.Status{},
+		},
+		"login": {
+			Summary: "Start session of dashboard, sets session cookie",
+			Request: ApiLoginPayload{},
+		},
+		"logout": {
+			Summary: "End session of dashboard",
+		},
+		"root": {
+			Summary:     "Redirect to dashboard",
+			ContentType: "text/html",
+		},
+		"dashboard": {
+			Summary:     "Web dashboard",
+			ContentType: "text/html",
+		},
+		"version": {
+			Summary:  "Version of server",
+			Response: ApiVersionData{},
+		},
+		"targets": {
+			Summary:  "Registered targets",
+			Response: []ApiTargetData{},
+		},
+		"target_wake": {
+			Summary:  "Wake registered target, running its hooks",
+			Response: controller.ActionResult{},
+			Query:    []queryParamDoc{asyncQueryParam},
+			Async:    true,
+		},
+		"target_halt": {
+			Summary:  "Halt registered target, running its hooks",
+			Response: controller.ActionResult{},
+			Query:    []queryParamDoc{asyncQueryParam},
+			Async:    true,
+		},
+		"target_reboot": {
+			Summary:  "Reboot registered target",
+			Response: controller.ActionResult{},
+			Query:    []queryParamDoc{asyncQueryParam},
+			Async:    true,
+		},
+		"exec": {
+			Summary:  "Run command configured on registered target",
+			Response: controller.ExecResult{},
+			Query:    []queryParamDoc{asyncQueryParam},
+			Async:    true,
+		},
+		"jobs": {
+			Summary:  "Retained jobs, newest first",
+			Response: []ApiJobData{},
+		},
+		"job": {
+			Summary:  "Progress and result of job",
+			Response: ApiJobData{},
+		},
+		"cancel_job": {
+			Summary:  "Cancel job",
+			Response: ApiJobData{},
+		},
+		"known_host": {
+			Summary:  "Host key of registered target and whether it is known",
+			Response: ApiHostKeyData{},
+			Query:    []queryParamDoc{{"port", "integer", "SSH port, 22 by default"}},
+		},
+		"trust_known_host": {
+			Summary:  "Record host key of registered target in known_hosts of server",
+			Request:  ApiTrustHostKeyPayload{},
+			Response: ApiHostKeyData{},
+		},
+		"remove_known_host": {
+			Summary: "Remove host keys of registered target from known_hosts of server",
+			Query:   []queryParamDoc{{"port", "integer", "SSH port, 22 by default"}},
+		},
+		"status_stream": {
+			Summary:   "WebSocket stream of status updates and actions",
+			WebSocket: true,
+		},
+		"events": {
+			Summary:     "Server-sent stream of events",
+			ContentType: "text/event-stream",
+			Query: []queryParamDoc{
+				{"target", "string", "Only events of targets, repeated or comma separated"},
+				{"type", "string", "Only events of types, repeated or comma separated"},
+				{"last_event_id", "integer", "Replay events after this id"},
+			},
+		},
+		"metrics": {
+			Summary:     "Prometheus metrics",
+			ContentType: "text/plain",
+		},
+		"openapi": {
+			Summary:     "This document",
+			ContentType: "application/json",
+		},
+	}
+}
+
+func (h *httpApiHandler) OpenApi(w http.ResponseWriter, r *http.Request) {
+	h.openApiOnce.Do(func() {
+		h.openApiDocument, h.openApiErr = json.Marshal(buildOpenApiDocument(h.router, h.version))
+	})
+
+	if h.openApiErr != nil {
+		http.Error(w, h.openApiErr.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(h.openApiDocument)
+}
+
+type openApiState struct {
+	openApiOnce     sync.Once
+	openApiDocument []byte
+	openApiErr      error
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+func buildOpenApiDocument(router *mux.Router, version string) map[string]interface{} {
+	docs := routeDocs()
+	schemas := openApiSchemas{components: make(map[string]interface{})}
+	paths := make(map[string]map[string]interface{})
+
+	router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{"GET"}
+		}
+
+		doc := docs[route.GetName()]
+		path := pathParamPattern.ReplaceAllString(template, "{$1}")
+		if paths[path] == nil {
+			paths[path] = make(map[string]interface{})
+		}
+
+		for _, method := range methods {
+			paths[path][strings.ToLower(method)] = schemas.operation(route.GetName(), template, doc)
+		}
+		return nil
+	})
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "homecontroller",
+			"version": version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":   "http",
+					"scheme": "bearer",
+				},
+			},
+		},
+		// token is required only when server is started with one
+		"security": []interface{}{
+			map[string]interface{}{},
+			map[string]interface{}{"bearerAuth": []string{}},
+		},
+	}
+}
+
+type openApiSchemas struct {
+	components map[string]interface{}
+}
+
+func (s openApiSchemas) operation(name string, template string, doc routeDoc) map[string]interface{} {
+	summary := doc.Summary
+	if summary == "" {
+		summary = name
+	}
+
+	var parameters []interface{}
+	for _, match := range pathParamPattern.FindAllStringSubmatch(template, -1) {
+		parameters = append(parameters, map[string]interface{}{
+			"name":     match[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+
+	for _, query := range doc.Query {
+		parameters = append(parameters, map[string]interface{}{
+			"name":        query.Name,
+			"in":          "query",
+			"description": query.Description,
+			"schema":      map[string]interface{}{"type": query.Type},
+		})
+	}
+
+	errorResponse := map[string]interface{}{
+		"description": "Error",
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": s.schemaOf(reflect.TypeOf(responseError{})),
+			},
+		},
+	}
+
+	responses := map[string]interface{}{"default": errorResponse}
+	switch {
+	case doc.WebSocket:
+		responses["101"] = map[string]interface{}{"description": "Switching to WebSocket protocol"}
+	case doc.Response != nil:
+		responses["200"] = map[string]interface{}{
+			"description": "Success",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": s.schemaOf(reflect.TypeOf(doc.Response)),
+				},
+			},
+		}
+	case doc.ContentType != "":
+		responses["200"] = map[string]interface{}{
+			"description": "Success",
+			"content": map[string]interface{}{
+				doc.ContentType: map[string]interface{}{"schema": map[string]interface{}{}},
+			},
+		}
+	default:
+		responses["204"] = map[string]interface{}{"description": "Success"}
+	}
+
+	if doc.Async {
+		responses["202"] = map[string]interface{}{
+			"description": "Job started",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": s.schemaOf(reflect.TypeOf(ApiJobData{})),
+				},
+			},
+		}
+	}
+
+	operation := map[string]interface{}{
+		"operationId": name,
+		"summary":     summary,
+		"responses":   responses,
+	}
+
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+
+	if doc.Request != nil {
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": s.schemaOf(reflect.TypeOf(doc.Request)),
+				},
+			},
+		}
+	}
+
+	return operation
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaOf returns JSON schema of type, structs are registered as
+// components and referenced.
+func (s openApiSchemas) schemaOf(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]interface{}{"type": "integer", "description": "nanoseconds"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	case reflect.TypeOf(config.HwAddress("")):
+		return map[string]interface{}{"type": "string", "format": "mac"}
+	case reflect.TypeOf(config.IP("")):
+		return map[string]interface{}{"type": "string", "format": "ip"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return s.schemaOf(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": s.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.schemaOf(t.Elem())}
+	case reflect.Struct:
+		name := strings.TrimPrefix(t.Name(), "Api")
+		name = strings.ToUpper(name[:1]) + name[1:]
+		if t == reflect.TypeOf(responseError{}) {
+			name = "Error"
+		}
+		if _, ok := s.components[name]; !ok {
+			// placeholder stops recursion of self referencing types
+			s.components[name] = map[string]interface{}{}
+			s.components[name] = s.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func (s openApiSchemas) structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+
+	var collect func(t reflect.Type)
+	collect = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+			if field.Anonymous && tag == "" {
+				collect(field.Type)
+				continue
+			}
+
+			if !field.IsExported() || tag == "-" {
+				continue
+			}
+
+			parts := strings.Split(tag, ",")
+			name := parts[0]
+			if name == "" {
+				name = field.Name
+			}
+
+			properties[name] = s.schemaOf(field.Type)
+
+			optional := field.Type.Kind() == reflect.Pointer
+			for _, option := range parts[1:] {
+				if option == "omitempty" {
+					optional = true
+				}
+			}
+			if !optional {
+				required = append(required, name)
+			}
+		}
+	}
+	collect(t)
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+
+	return schema
+}
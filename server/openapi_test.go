@@ -0,0 +1,38 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// TestRouteDocs checks that routeDocs stay in sync with the router, so that
+// no route is left undocumented and no doc outlives its route.
+func TestRouteDocs(t *testing.T) {
+	handler := InitApiCore().(*httpApiHandler)
+	handler.EnableDashboard()
+
+	docs := routeDocs()
+	routes := make(map[string]bool)
+	err := handler.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		name := route.GetName()
+		if name == "" {
+			return nil
+		}
+
+		routes[name] = true
+		if _, ok := docs[name]; !ok {
+			t.Errorf("route '%s' has no entry in routeDocs", name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("couldnt walk router, %v", err)
+	}
+
+	for name := range docs {
+		if !routes[name] {
+			t.Errorf("routeDocs entry '%s' matches no route", name)
+		}
+	}
+}
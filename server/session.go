@@ -0,0 +1,189 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCookieName = "homecontroller_session"
+	sessionLifetime   = 7 * 24 * time.Hour
+)
+
+// sessionStore keeps sessions of browsers logged in with auth token. Sessions
+// are kept in memory only, restart of server logs everybody out.
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]time.Time
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{sessions: make(map[string]time.Time)}
+}
+
+func (s *sessionStore) Create() (string, time.Time, error) {
+	bts := make([]byte, 32)
+	if _, err := rand.Read(bts); err != nil {
+		return "", time.Time{}, err
+	}
+
+	id := hex.EncodeToString(bts)
+	expires := time.Now().Add(sessionLifetime)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cleanup()
+	s.sessions[id] = expires
+	return id, expires, nil
+}
+
+func (s *sessionStore) Valid(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expires, ok := s.sessions[id]
+	if !ok {
+		return false
+	}
+
+	if time.Now().After(expires) {
+		delete(s.sessions, id)
+		return false
+	}
+
+	return true
+}
+
+func (s *sessionStore) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, id)
+}
+
+func (s *sessionStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clear(s.sessions)
+}
+
+func (s *sessionStore) cleanup() {
+	now := time.Now()
+	for id, expires := range s.sessions {
+		if now.After(expires) {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// publicRoutes are accessible without token, so that browser can load
+// dashboard and log in.
+var publicRoutes = map[string]bool{
+	"root":      true,
+	"dashboard": true,
+	"login":     true,
+	"logout":    true,
+}
+
+func tokenEquals(token string, authToken string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(authToken)) == 1
+}
+
+// authMiddleware accepts requests with Bearer token or with cookie of valid
+// session.
+func (h *httpApiHandler) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authToken := h.currentAuthToken()
+		if authToken == "" || publicRoutes[currentRouteName(r)] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if token, ok := bearerToken(r); ok && tokenEquals(token, authToken) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if cookie, err := r.Cookie(sessionCookieName); err == nil && h.sessions.Valid(cookie.Value) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Write an error and stop the handler chain
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	})
+}
+
+// SetAuthToken sets token required by API. Sessions started with previous
+// token end when the token changes.
+func (h *httpApiHandler) SetAuthToken(authToken string) {
+	h.configMu.Lock()
+	defer h.configMu.Unlock()
+
+	if h.authToken != authToken {
+		h.sessions.Clear()
+	}
+	h.authToken = authToken
+}
+
+func (h *httpApiHandler) currentAuthToken() string {
+	h.configMu.RLock()
+	defer h.configMu.RUnlock()
+
+	return h.authToken
+}
+
+// Login starts session of browser when request contains correct token.
+func (h *httpApiHandler) Login(r *http.Request) (interface{}, error) {
+	authToken := h.currentAuthToken()
+	if authToken == "" {
+		return nil, nil
+	}
+
+	payload, err := parseLoginPayload(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !tokenEquals(payload.Token, authToken) {
+		return nil, invalidTokenError{errors.New("invalid token")}
+	}
+
+	id, expires, err := h.sessions.Create()
+	if err != nil {
+		return nil, internalError{err}
+	}
+
+	return cookieResponse{cookie: &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    id,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	}}, nil
+}
+
+func (h *httpApiHandler) Logout(r *http.Request) (interface{}, error) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		h.sessions.Delete(cookie.Value)
+	}
+
+	return cookieResponse{cookie: &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	}}, nil
+}
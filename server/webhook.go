@@ -0,0 +1,237 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"homecontroller/config"
+	"homecontroller/events"
+	"homecontroller/secret"
+)
+
+const (
+	webhookSignatureHeader = "X-Homecontroller-Signature"
+	webhookTimestampHeader = "X-Homecontroller-Timestamp"
+	webhookEventHeader     = "X-Homecontroller-Event"
+
+	webhookDefaultMaxAttempts = 5
+	webhookInitialBackoff     = time.Second
+	webhookQueueSize          = 128
+)
+
+// WebhookTolerance is how old timestamp of delivery receivers should accept.
+// Older deliveries are possibly replayed and should be rejected.
+const WebhookTolerance = 5 * time.Minute
+
+type webhookDelivery struct {
+	event events.Event
+	body  []byte
+}
+
+type deadLetterEntry struct {
+	Url      string       `json:"url"`
+	Event    events.Event `json:"event"`
+	Attempts int          `json:"attempts"`
+	Error    string       `json:"error"`
+	FailedAt time.Time    `json:"failed_at"`
+}
+
+// WebhookDispatcher delivers events to configured endpoints. Each endpoint
+// has its own queue, so a failing endpoint doesn't delay the others.
+type WebhookDispatcher struct {
+	config config.WebhooksConfiguration
+	client *http.Client
+
+	deadLetterMu sync.Mutex
+	unsubscribe  func()
+}
+
+func NewWebhookDispatcher(config config.WebhooksConfiguration) *WebhookDispatcher {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = webhookDefaultMaxAttempts
+	}
+
+	return &WebhookDispatcher{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (d *WebhookDispatcher) Start() {
+	queues := make([]chan webhookDelivery, len(d.config.Endpoints))
+	for i := range d.config.Endpoints {
+		queues[i] = make(chan webhookDelivery, webhookQueueSize)
+		go d.runEndpoint(&d.config.Endpoints[i], queues[i])
+	}
+
+	ch, unsubscribe := events.Subscribe()
+	d.unsubscribe = unsubscribe
+
+	go func() {
+		defer func() {
+			for _, queue := range queues {
+				close(queue)
+			}
+		}()
+
+		for event := range ch {
+			body, err := json.Marshal(event)
+			if err != nil {
+				log.Errorf("Webhook: could not marshal event %d: %v", event.Id, err)
+				continue
+			}
+
+			for i := range d.config.Endpoints {
+				endpoint := &d.config.Endpoints[i]
+				if !endpoint.Accepts(event.Type) {
+					continue
+				}
+
+				select {
+				case queues[i] <- webhookDelivery{event, body}:
+				default:
+					d.deadLetter(endpoint, event, 0, fmt.Errorf("delivery queue is full"))
+				}
+			}
+		}
+	}()
+}
+
+func (d *WebhookDispatcher) Stop() {
+	if d.unsubscribe != nil {
+		d.unsubscribe()
+	}
+}
+
+func (d *WebhookDispatcher) runEndpoint(endpoint *config.WebhookConfiguration, queue chan webhookDelivery) {
+	for delivery := range queue {
+		backoff := webhookInitialBackoff
+
+		var err error
+		for attempt := 1; attempt <= d.config.MaxAttempts; attempt++ {
+			err = d.deliver(endpoint, delivery)
+			if err == nil {
+				break
+			}
+
+			log.Warningf("Webhook: delivery of event %d to %s failed (attempt %d/%d): %v", delivery.event.Id, endpoint.Url, attempt, d.config.MaxAttempts, err)
+			if attempt < d.config.MaxAttempts {
+				time.Sleep(backoff)
+				backoff *= 2
+			}
+		}
+
+		if err != nil {
+			d.deadLetter(endpoint, delivery.event, d.config.MaxAttempts, err)
+		}
+	}
+}
+
+func (d *WebhookDispatcher) deliver(endpoint *config.WebhookConfiguration, delivery webhookDelivery) error {
+	req, err := http.NewRequest("POST", endpoint.Url, bytes.NewReader(delivery.body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set(webhookEventHeader, delivery.event.Type)
+	if endpoint.Secret != "" {
+		webhookSecret, err := secret.Ref(endpoint.Secret).Resolve()
+		if err != nil {
+			return err
+		}
+
+		// each attempt is signed again, so that retries are not too old
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(webhookTimestampHeader, timestamp)
+		req.Header.Set(webhookSignatureHeader, signWebhookBody(webhookSecret, timestamp, delivery.body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (d *WebhookDispatcher) deadLetter(endpoint *config.WebhookConfiguration, event events.Event, attempts int, err error) {
+	log.Errorf("Webhook: giving up delivery of event %d to %s: %v", event.Id, endpoint.Url, err)
+	if d.config.DeadLetterPath == "" {
+		return
+	}
+
+	bts, err := json.Marshal(deadLetterEntry{
+		Url:      endpoint.Url,
+		Event:    event,
+		Attempts: attempts,
+		Error:    err.Error(),
+		FailedAt: time.Now(),
+	})
+	if err != nil {
+		log.Errorf("Webhook: could not marshal dead letter: %v", err)
+		return
+	}
+
+	d.deadLetterMu.Lock()
+	defer d.deadLetterMu.Unlock()
+
+	file, err := os.OpenFile(d.config.DeadLetterPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		log.Errorf("Webhook: could not open dead letter log: %v", err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(bts, '\n')); err != nil {
+		log.Errorf("Webhook: could not write dead letter log: %v", err)
+	}
+}
+
+// signWebhookBody returns value of signature header, HMAC-SHA256 of
+// timestamp, "." and the body using the shared secret. Timestamp is unix time
+// sent in timestamp header, so that captured delivery cannot be replayed
+// later.
+func signWebhookBody(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks signature of received delivery and that its timestamp
+// is within WebhookTolerance, for receivers written in Go.
+func VerifyWebhook(secret string, header http.Header, body []byte) error {
+	timestamp := header.Get(webhookTimestampHeader)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook timestamp '%s'", timestamp)
+	}
+
+	age := time.Since(time.Unix(unix, 0))
+	if age > WebhookTolerance || age < -WebhookTolerance {
+		return fmt.Errorf("webhook timestamp is outside of tolerance, %v old", age.Round(time.Second))
+	}
+
+	expected := signWebhookBody(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(header.Get(webhookSignatureHeader))) {
+		return errors.New("invalid webhook signature")
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signedWebhookHeader(secret string, sentAt time.Time, body []byte) http.Header {
+	timestamp := strconv.FormatInt(sentAt.Unix(), 10)
+
+	header := http.Header{}
+	header.Set(webhookTimestampHeader, timestamp)
+	header.Set(webhookSignatureHeader, signWebhookBody(secret, timestamp, body))
+	return header
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	body := []byte(`{"id":1,"type":"target.online"}`)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		body   []byte
+		valid  bool
+	}{
+		{"fresh delivery", signedWebhookHeader("secret", time.Now(), body), body, true},
+		{"other secret", signedWebhookHeader("other", time.Now(), body), body, false},
+		{"changed body", signedWebhookHeader("secret", time.Now(), body), []byte(`{"id":2}`), false},
+		{"replayed delivery", signedWebhookHeader("secret", time.Now().Add(-WebhookTolerance-time.Minute), body), body, false},
+		{"missing timestamp", http.Header{webhookSignatureHeader: {signWebhookBody("secret", "", body)}}, body, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := VerifyWebhook("secret", test.header, test.body)
+			if test.valid && err != nil {
+				t.Errorf("valid delivery was rejected: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Error("invalid delivery was accepted")
+			}
+		})
+	}
+}
+
+func TestWebhookSignatureCoversTimestamp(t *testing.T) {
+	body := []byte(`{}`)
+	if signWebhookBody("secret", "1700000000", body) == signWebhookBody("secret", "1700000001", body) {
+		t.Error("signature does not depend on timestamp")
+	}
+}
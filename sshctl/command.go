@@ -0,0 +1,131 @@
+package sshctl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const sessionCloseTimeout = 5 * time.Second
+
+// Result of command, exit code is -1 when command did not report any.
+type Result struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// Halt powers off destination, sudo is used unless connected as root.
+func Halt(ctx context.Context, dest Destination, prompt PromptFunc) error {
+	return DefaultPool.Halt(ctx, dest, prompt)
+}
+
+// Reboot restarts destination, sudo is used unless connected as root.
+func Reboot(ctx context.Context, dest Destination, prompt PromptFunc) error {
+	return DefaultPool.Reboot(ctx, dest, prompt)
+}
+
+// Run runs cmd on destination using connection from DefaultPool.
+func Run(ctx context.Context, dest Destination, cmd string, timeout time.Duration, prompt PromptFunc) (Result, error) {
+	return DefaultPool.Run(ctx, dest, cmd, timeout, prompt)
+}
+
+func (p *Pool) Halt(ctx context.Context, dest Destination, prompt PromptFunc) error {
+	return p.runPowerCommand(ctx, dest, "halt -p", prompt)
+}
+
+func (p *Pool) Reboot(ctx context.Context, dest Destination, prompt PromptFunc) error {
+	return p.runPowerCommand(ctx, dest, "reboot", prompt)
+}
+
+func (p *Pool) runPowerCommand(ctx context.Context, dest Destination, cmd string, prompt PromptFunc) error {
+	dest = dest.Resolve()
+
+	shouldSudo := dest.User != "root"
+	if shouldSudo {
+		cmd = "sudo " + cmd
+	}
+
+	// ignore result of command, connection is usually dropped by the host
+	_, err := p.Run(ctx, dest, cmd, 0, prompt)
+	return err
+}
+
+// Run runs cmd on destination and returns its output and exit code.
+// Returned error concerns only connection, timeout and cancellation, failing
+// command is not an error. Zero timeout means no timeout.
+func (p *Pool) Run(ctx context.Context, dest Destination, cmd string, timeout time.Duration, prompt PromptFunc) (Result, error) {
+	dest = dest.Resolve()
+
+	client, release, err := p.Acquire(ctx, dest.Hops(), prompt)
+	if err != nil {
+		return Result{}, err
+	}
+
+	defer release()
+
+	session, err := client.NewSession()
+	if err != nil {
+		// connection is unusable, drop it from pool
+		client.Close()
+		return Result{}, Error{fmt.Errorf("couldnt create client session, %s", err), "ssh_session"}
+	}
+
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Run(cmd)
+	}()
+
+	var timeoutC <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutC = timer.C
+	}
+
+	var abortErr error
+	select {
+	case err = <-done:
+	case <-timeoutC:
+		abortErr = Error{fmt.Errorf("command did not finish within %v", timeout), "timeout"}
+	case <-ctx.Done():
+		abortErr = ctx.Err()
+	}
+
+	if abortErr != nil {
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+
+		// client is shared, close it only when session does not end
+		select {
+		case <-done:
+		case <-time.After(sessionCloseTimeout):
+			client.Close()
+			<-done
+		}
+		return Result{Stdout: stdout.String(), Stderr: stderr.String(), ExitCode: -1}, abortErr
+	}
+
+	result := Result{Stdout: stdout.String(), Stderr: stderr.String()}
+
+	var exitErr *ssh.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitStatus()
+	default:
+		result.ExitCode = -1
+	}
+
+	return result, nil
+}
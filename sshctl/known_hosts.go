@@ -0,0 +1,316 @@
+package sshctl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// knownHostsFile overrides default ~/.ssh/known_hosts, so that server can
+// keep its own file independent of home directory of service user. It is
+// replaced on config reload while connections read it.
+var knownHostsFile atomic.Pointer[string]
+
+var knownHostsMu sync.Mutex
+
+// SetKnownHostsFile replaces ~/.ssh/known_hosts by path, empty path restores
+// the default.
+func SetKnownHostsFile(path string) {
+	knownHostsFile.Store(&path)
+}
+
+// HostKeyInfo describes host key of server and whether known_hosts knows it.
+type HostKeyInfo struct {
+	Host        string
+	Type        string
+	Fingerprint string
+	Known       bool
+	Changed     bool
+}
+
+type UnknownHostKeyError struct {
+	Addr        string
+	Fingerprint string
+}
+
+func (e UnknownHostKeyError) Error() string {
+	return fmt.Sprintf("host key %s of %s is not known, trust it with 'ssh trust' command or pin it with 'host_key'", e.Fingerprint, e.Addr)
+}
+
+type ChangedHostKeyError struct {
+	Addr        string
+	Fingerprint string
+}
+
+func (e ChangedHostKeyError) Error() string {
+	return fmt.Sprintf("host key %s of %s does not match known key, host may have been reinstalled or connection is intercepted", e.Fingerprint, e.Addr)
+}
+
+func KnownHostsPath() (string, error) {
+	if path := knownHostsFile.Load(); path != nil && *path != "" {
+		return *path, nil
+	}
+
+	usr, err := user.Current()
+	if err != nil {
+		return "", fmt.Errorf("couldnt access user home %s", err)
+	}
+
+	return fmt.Sprintf("%s/.ssh/known_hosts", usr.HomeDir), nil
+}
+
+func sshKnownHosts() (ssh.HostKeyCallback, error) {
+	path, err := KnownHostsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("couldnt access known_hosts directory %s", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("couldnt access known_hosts file %s", err)
+	}
+	defer file.Close()
+
+	hostKeyCallback, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldnt create new knownhosts %s", err)
+	}
+
+	return hostKeyCallback, err
+}
+
+// HostKeyCallback verifies host key against fingerprint pinned in
+// configuration, or against known_hosts when there is none.
+func HostKeyCallback(dest Destination) (ssh.HostKeyCallback, error) {
+	if dest.HostKey != "" {
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			fingerprint := ssh.FingerprintSHA256(key)
+			if fingerprint != dest.HostKey && strings.TrimPrefix(fingerprint, "SHA256:") != dest.HostKey {
+				return ChangedHostKeyError{hostname, fingerprint}
+			}
+			return nil
+		}, nil
+	}
+
+	knownHostsCallback, err := sshKnownHosts()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := knownHostsCallback(hostname, remote, key)
+
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) {
+			if len(keyErr.Want) == 0 {
+				return UnknownHostKeyError{hostname, ssh.FingerprintSHA256(key)}
+			}
+			return ChangedHostKeyError{hostname, ssh.FingerprintSHA256(key)}
+		}
+
+		return err
+	}, nil
+}
+
+// FetchHostKey performs SSH handshake only to obtain host key of server,
+// the key is not verified in any way. Jump hosts of destination are used to
+// reach the server.
+func FetchHostKey(ctx context.Context, dest Destination) (ssh.PublicKey, error) {
+	var hostKey ssh.PublicKey
+	config := &ssh.ClientConfig{
+		User: "homecontroller",
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			hostKey = key
+			return nil
+		},
+		Timeout: 10 * time.Second,
+	}
+
+	addr := dest.Addr()
+	var conn net.Conn
+	if len(dest.Jumps) > 0 {
+		via, err := DialChain(ctx, dest.Jumps, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer via.Close()
+
+		conn, err = via.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("couldnt connect to %s from jump host, %v", addr, err)
+		}
+	} else {
+		dialer := net.Dialer{Timeout: config.Timeout}
+		var err error
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("couldnt connect to %s, %v", addr, err)
+		}
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stop()
+
+	// handshake fails on authentication, host key is already received by then
+	client, _, _, err := ssh.NewClientConn(conn, addr, config)
+	if client != nil {
+		client.Close()
+	}
+
+	if hostKey == nil {
+		return nil, fmt.Errorf("ssh handshake failed, %v", err)
+	}
+
+	return hostKey, nil
+}
+
+func AddKnownHost(addr string, key ssh.PublicKey) error {
+	path, err := KnownHostsPath()
+	if err != nil {
+		return err
+	}
+
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("couldnt open known_hosts file %s", err)
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintln(file, knownhosts.Line([]string{knownhosts.Normalize(addr)}, key))
+	return err
+}
+
+// RemoveKnownHost removes all plain (not hashed) known_hosts entries of addr
+// and returns how many were removed.
+func RemoveKnownHost(addr string) (int, error) {
+	path, err := KnownHostsPath()
+	if err != nil {
+		return 0, err
+	}
+
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+
+	bts, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	normalized := knownhosts.Normalize(addr)
+	removed := 0
+
+	var kept []string
+	scanner := bufio.NewScanner(strings.NewReader(string(bts)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) > 0 && !strings.HasPrefix(fields[0], "#") && !strings.HasPrefix(fields[0], "@") {
+			hosts := strings.Split(fields[0], ",")
+			if slices.Contains(hosts, normalized) {
+				removed++
+				continue
+			}
+		}
+		kept = append(kept, line)
+	}
+
+	if removed == 0 {
+		return 0, nil
+	}
+
+	content := strings.Join(kept, "\n")
+	if len(kept) > 0 {
+		content += "\n"
+	}
+
+	return removed, os.WriteFile(path, []byte(content), 0644)
+}
+
+// InspectHostKey obtains host key of destination and checks it against
+// known_hosts.
+func InspectHostKey(ctx context.Context, dest Destination) (ssh.PublicKey, HostKeyInfo, error) {
+	dest = dest.Resolve()
+	dest.HostKey = ""
+	addr := dest.Addr()
+
+	key, err := FetchHostKey(ctx, dest)
+	if err != nil {
+		return nil, HostKeyInfo{}, err
+	}
+
+	data := HostKeyInfo{
+		Host:        addr,
+		Type:        key.Type(),
+		Fingerprint: ssh.FingerprintSHA256(key),
+	}
+
+	hostKeyCallback, err := HostKeyCallback(dest)
+	if err != nil {
+		return nil, data, err
+	}
+
+	tcpAddr, _ := net.ResolveTCPAddr("tcp", addr)
+	switch err := hostKeyCallback(addr, tcpAddr, key).(type) {
+	case nil:
+		data.Known = true
+	case UnknownHostKeyError:
+	case ChangedHostKeyError:
+		data.Changed = true
+	default:
+		return nil, data, err
+	}
+
+	return key, data, nil
+}
+
+// TrustHostKey records key in known_hosts, replacing previous keys of the
+// host when it has changed.
+func TrustHostKey(key ssh.PublicKey, data HostKeyInfo) error {
+	if data.Known {
+		return nil
+	}
+
+	if data.Changed {
+		removed, err := RemoveKnownHost(data.Host)
+		if err != nil {
+			return fmt.Errorf("couldnt remove previous host key, %v", err)
+		}
+		log.Infof("Removed %d previous host keys of %s", removed, data.Host)
+	}
+
+	if err := AddKnownHost(data.Host, key); err != nil {
+		return fmt.Errorf("couldnt record host key, %v", err)
+	}
+
+	log.Infof("Trusted host key %s of %s", data.Fingerprint, data.Host)
+	return nil
+}
@@ -0,0 +1,199 @@
+package sshctl
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const DefaultIdleTimeout = 5 * time.Minute
+
+const sshKeepaliveTimeout = 3 * time.Second
+
+// DefaultPool keeps authenticated connections for reuse by exec, halt and
+// probes.
+var DefaultPool = NewPool(DefaultIdleTimeout)
+
+type pooledSshClient struct {
+	client   *ssh.Client
+	users    int
+	lastUsed time.Time
+}
+
+// Pool reuses SSH clients keyed by destination chain and hash of its
+// credentials, so that request with different credentials never reuses
+// connection authenticated by other ones. Clients unused for idle timeout
+// are closed, zero timeout disables pooling.
+type Pool struct {
+	mu      sync.Mutex
+	idle    time.Duration
+	clients map[string]*pooledSshClient
+
+	janitorOnce sync.Once
+}
+
+func NewPool(idle time.Duration) *Pool {
+	return &Pool{
+		idle:    idle,
+		clients: make(map[string]*pooledSshClient),
+	}
+}
+
+func (p *Pool) SetIdleTimeout(idle time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.idle = idle
+}
+
+// Acquire returns connected client to the last of hops, dialing through the
+// others. Release must be called when client is no longer used, it must not
+// be closed unless connection should be discarded.
+func (p *Pool) Acquire(ctx context.Context, hops []Destination, prompt PromptFunc) (*ssh.Client, func(), error) {
+	key := sshChainKey(hops)
+
+	p.mu.Lock()
+	idle := p.idle
+	pooled := p.clients[key]
+	if pooled != nil {
+		pooled.users++
+	}
+	p.mu.Unlock()
+
+	if pooled != nil {
+		if sshClientAlive(pooled.client) {
+			return pooled.client, p.releaseFunc(pooled), nil
+		}
+
+		p.release(pooled)
+		p.discard(key, pooled)
+	}
+
+	client, err := DialChain(ctx, hops, prompt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if idle <= 0 {
+		return client, func() { client.Close() }, nil
+	}
+
+	p.janitorOnce.Do(func() {
+		go p.runJanitor()
+	})
+
+	pooled = &pooledSshClient{client: client, users: 1}
+
+	p.mu.Lock()
+	if existing := p.clients[key]; existing != nil {
+		// other caller dialed meanwhile, keep both but pool only the first
+		p.mu.Unlock()
+		return client, func() { client.Close() }, nil
+	}
+	p.clients[key] = pooled
+	p.mu.Unlock()
+
+	go func() {
+		client.Wait()
+		p.discard(key, pooled)
+	}()
+
+	return client, p.releaseFunc(pooled), nil
+}
+
+func (p *Pool) releaseFunc(pooled *pooledSshClient) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.release(pooled)
+		})
+	}
+}
+
+func (p *Pool) release(pooled *pooledSshClient) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pooled.users--
+	pooled.lastUsed = time.Now()
+}
+
+func (p *Pool) discard(key string, pooled *pooledSshClient) {
+	p.mu.Lock()
+	if p.clients[key] == pooled {
+		delete(p.clients, key)
+	}
+	p.mu.Unlock()
+
+	pooled.client.Close()
+}
+
+func (p *Pool) runJanitor() {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		p.closeIdle()
+	}
+}
+
+func (p *Pool) closeIdle() {
+	p.mu.Lock()
+	var expired []*pooledSshClient
+	for key, pooled := range p.clients {
+		if pooled.users == 0 && time.Since(pooled.lastUsed) > p.idle {
+			delete(p.clients, key)
+			expired = append(expired, pooled)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, pooled := range expired {
+		log.Debugf("Closing idle SSH connection to %s", pooled.client.RemoteAddr())
+		pooled.client.Close()
+	}
+}
+
+// sshClientAlive checks connection by keepalive request, which server
+// answers even when it does not support it. Connection which does not answer
+// within sshKeepaliveTimeout counts as dead.
+func sshClientAlive(client *ssh.Client) bool {
+	alive := make(chan bool, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		alive <- err == nil
+	}()
+
+	timer := time.NewTimer(sshKeepaliveTimeout)
+	defer timer.Stop()
+
+	select {
+	case ok := <-alive:
+		return ok
+	case <-timer.C:
+		return false
+	}
+}
+
+// sshChainKey identifies chain by its hops followed by hash of their
+// credentials, so that no secret is kept in the pool.
+func sshChainKey(hops []Destination) string {
+	var addrs []string
+	credentials := sha256.New()
+	for _, hop := range hops {
+		addrs = append(addrs, hop.User+"@"+hop.Addr())
+		fmt.Fprintf(credentials, "%s|%s|", string(hop.Password), hop.Certificate)
+		if hop.PrivateKey != nil {
+			fmt.Fprintf(credentials, "%s|%s|", hop.PrivateKey.Path, string(hop.PrivateKey.Passphrase))
+		}
+		fmt.Fprintf(credentials, "%s|%t\n", hop.HostKey, hop.Explicit)
+	}
+
+	return strings.Join(addrs, ",") + "#" + hex.EncodeToString(credentials.Sum(nil))
+}
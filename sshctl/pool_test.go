@@ -0,0 +1,36 @@
+package sshctl
+
+import (
+	"strings"
+	"testing"
+
+	"homecontroller/secret"
+)
+
+func TestSshChainKey(t *testing.T) {
+	port := 2222
+	jump := Destination{User: "jump", Host: "bastion", Password: secret.Plain("bastion-password")}
+	dest := Destination{User: "root", Host: "nas", Port: &port, Password: secret.Plain("nas-password"), PrivateKey: &PrivateKey{Path: "id_ed25519", Passphrase: secret.Plain("key-passphrase")}}
+
+	key := sshChainKey([]Destination{jump, dest})
+	if !strings.HasPrefix(key, "jump@bastion:22,root@nas:2222#") {
+		t.Errorf("key %q does not start with hops", key)
+	}
+	for _, value := range []string{"bastion-password", "nas-password", "key-passphrase"} {
+		if strings.Contains(key, value) {
+			t.Errorf("key %q contains secret %q", key, value)
+		}
+	}
+
+	other := dest
+	other.Password = secret.Plain("other-password")
+	if sshChainKey([]Destination{jump, other}) == key {
+		t.Error("key does not depend on password")
+	}
+
+	explicit := dest
+	explicit.Explicit = true
+	if sshChainKey([]Destination{jump, explicit}) == key {
+		t.Error("key does not depend on explicit destination")
+	}
+}
@@ -0,0 +1,498 @@
+// Package sshctl connects to machines over SSH to halt them and run
+// commands. Connection settings missing in Destination are taken from
+// ~/.ssh/config and keys of running ssh-agent are used as well, unless the
+// destination is explicit.
+package sshctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"homecontroller/secret"
+
+	"github.com/kevinburke/ssh_config"
+	"github.com/op/go-logging"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+var log = logging.MustGetLogger("sshctl")
+
+// PromptFunc asks user for secret, e.g. passphrase of private key or answer
+// to keyboard-interactive question. Nil prompt means non-interactive use.
+type PromptFunc func(question string) (string, error)
+
+// Error carries a short machine readable reason of failure, used as metric
+// label and to tell host key problems apart.
+type Error struct {
+	Err    error
+	Reason string
+}
+
+func (e Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
+// Destination describes how to reach and authenticate to SSH server. Host
+// may be an alias from ~/.ssh/config, missing values are filled from
+// matching entry by Resolve.
+type Destination struct {
+	User        string
+	Host        string
+	Port        *int
+	Password    secret.Ref
+	PrivateKey  *PrivateKey
+	Certificate string
+	HostKey     string
+	// Jumps are dialed in order before the destination
+	Jumps []Destination
+	// Explicit destination is used as given, ~/.ssh/config is not applied
+	// and keys of ssh-agent are not offered, e.g. for hosts from API callers
+	Explicit bool
+
+	// identityFiles are additional keys from ssh_config, used only when
+	// they can be loaded
+	identityFiles []string
+	resolved      bool
+	isJump        bool
+}
+
+type PrivateKey struct {
+	Path       string
+	Passphrase secret.Ref
+}
+
+// FullPath returns path of key, relative paths are relative to ~/.ssh.
+func (k *PrivateKey) FullPath() (string, error) {
+	if path.IsAbs(k.Path) {
+		return k.Path, nil
+	} else {
+		usr, err := user.Current()
+		if err != nil {
+			return "", err
+		}
+
+		fullPath := fmt.Sprintf("%s/.ssh/%s", usr.HomeDir, k.Path)
+		return fullPath, nil
+	}
+}
+
+func (k *PrivateKey) Signer(prompt PromptFunc) (ssh.Signer, error) {
+	if k == nil {
+		return nil, errors.New("missing private key")
+	}
+
+	keyPath, err := k.FullPath()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	passphrase, err := k.Passphrase.Resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the Signer for this private key.
+	return sshAuthSigner(key, passphrase, prompt)
+}
+
+// ParseProxyJump parses ProxyJump value of ssh_config, which is comma
+// separated list of [user@]host[:port].
+func ParseProxyJump(value string) []Destination {
+	if value == "" || value == "none" {
+		return nil
+	}
+
+	var destinations []Destination
+	for _, hop := range strings.Split(value, ",") {
+		dest := Destination{isJump: true}
+		if at := strings.LastIndex(hop, "@"); at >= 0 {
+			dest.User = hop[:at]
+			hop = hop[at+1:]
+		}
+
+		if host, portStr, err := net.SplitHostPort(hop); err == nil {
+			if port, err := strconv.Atoi(portStr); err == nil {
+				hop = host
+				dest.Port = &port
+			}
+		}
+
+		dest.Host = hop
+		destinations = append(destinations, dest)
+	}
+
+	return destinations
+}
+
+// Resolve applies HostName, User, Port, IdentityFile, CertificateFile and
+// ProxyJump from ~/.ssh/config entry matching the host. Explicitly configured
+// values take precedence.
+func (d Destination) Resolve() Destination {
+	if d.resolved {
+		return d
+	}
+	d.resolved = true
+
+	alias := d.Host
+	get := func(key string) string {
+		if d.Explicit {
+			return ""
+		}
+		return ssh_config.Get(alias, key)
+	}
+
+	if hostName := get("HostName"); hostName != "" {
+		d.Host = hostName
+	}
+
+	if d.User == "" {
+		d.User = get("User")
+	}
+	if d.User == "" {
+		if usr, err := user.Current(); err == nil {
+			d.User = usr.Username
+		}
+	}
+
+	if d.Port == nil {
+		if port, err := strconv.Atoi(get("Port")); err == nil {
+			d.Port = &port
+		}
+	}
+
+	if d.Certificate == "" {
+		d.Certificate = get("CertificateFile")
+	}
+
+	if !d.Explicit {
+		for _, identityFile := range ssh_config.GetAll(alias, "IdentityFile") {
+			d.identityFiles = append(d.identityFiles, ExpandHomePath(identityFile))
+		}
+	}
+
+	// jump hosts do not chain further, whole chain is taken from target
+	if !d.isJump && len(d.Jumps) == 0 {
+		d.Jumps = ParseProxyJump(get("ProxyJump"))
+	}
+
+	jumps := make([]Destination, len(d.Jumps))
+	for i, jump := range d.Jumps {
+		jump.isJump = true
+		jump.Explicit = jump.Explicit || d.Explicit
+		jumps[i] = jump.Resolve()
+	}
+	d.Jumps = jumps
+
+	return d
+}
+
+func (d Destination) Addr() string {
+	return Addr(d.Host, d.Port)
+}
+
+// Hops returns jump hosts followed by the destination itself.
+func (d Destination) Hops() []Destination {
+	return append(append([]Destination{}, d.Jumps...), d)
+}
+
+func ExpandHomePath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if dirname, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(dirname, path[1:])
+		}
+	}
+
+	return path
+}
+
+func sshAuthSigner(pemKey []byte, passphrase string, prompt PromptFunc) (ssh.Signer, error) {
+	if len(passphrase) > 0 {
+		return ssh.ParsePrivateKeyWithPassphrase(pemKey, []byte(passphrase))
+	} else {
+		signer, err := ssh.ParsePrivateKey(pemKey)
+		if err != nil && prompt != nil {
+			if _, ok := err.(*ssh.PassphraseMissingError); ok {
+				passphrase, err := prompt("Enter passphrase for private key: ")
+				if err != nil {
+					return nil, fmt.Errorf("couldnt read passphrase, %v", err)
+				}
+				return sshAuthSigner(pemKey, passphrase, nil)
+			}
+		}
+		return signer, err
+	}
+}
+
+// sshCertSigner wraps signer with OpenSSH user certificate. When no
+// certificate path is given, '<key>-cert.pub' next to the key is used if it
+// exists.
+func sshCertSigner(signer ssh.Signer, keyPath string, certPath string) (ssh.Signer, error) {
+	if certPath == "" {
+		certPath = keyPath + "-cert.pub"
+		if _, err := os.Stat(certPath); err != nil {
+			return signer, nil
+		}
+	}
+
+	bts, err := os.ReadFile(ExpandHomePath(certPath))
+	if err != nil {
+		return nil, fmt.Errorf("couldnt read certificate, %s", err)
+	}
+
+	pubKey, _, _, _, err := ssh.ParseAuthorizedKey(bts)
+	if err != nil {
+		return nil, fmt.Errorf("couldnt parse certificate, %s", err)
+	}
+
+	cert, ok := pubKey.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("file %s is not a certificate", certPath)
+	}
+
+	return ssh.NewCertSigner(cert, signer)
+}
+
+// connectSshAgent connects to running ssh-agent, if there is any. Returned
+// function closes the connection, it must stay open until authentication
+// finishes as agent signs on behalf of the client.
+func connectSshAgent() (agent.ExtendedAgent, func()) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, func() {}
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		log.Debugf("Could not connect to ssh-agent: %v", err)
+		return nil, func() {}
+	}
+
+	return agent.NewClient(conn), func() {
+		conn.Close()
+	}
+}
+
+func (d Destination) signers(agentClient agent.ExtendedAgent, prompt PromptFunc) ([]ssh.Signer, error) {
+	var signers []ssh.Signer
+	if agentClient != nil && !d.Explicit {
+		agentSigners, err := agentClient.Signers()
+		if err != nil {
+			log.Debugf("Could not list ssh-agent keys: %v", err)
+		}
+		signers = append(signers, agentSigners...)
+	}
+
+	if d.PrivateKey != nil && d.PrivateKey.Path != "" {
+		signer, err := d.PrivateKey.Signer(prompt)
+		if err != nil {
+			return nil, err
+		}
+
+		keyPath, _ := d.PrivateKey.FullPath()
+		signer, err = sshCertSigner(signer, keyPath, d.Certificate)
+		if err != nil {
+			return nil, err
+		}
+
+		signers = append(signers, signer)
+	}
+
+	// keys from ssh_config are optional, unusable ones are skipped
+	for _, identityFile := range d.identityFiles {
+		key, err := os.ReadFile(identityFile)
+		if err != nil {
+			continue
+		}
+
+		signer, err := sshAuthSigner(key, "", prompt)
+		if err != nil {
+			log.Debugf("Skipping identity file %s: %v", identityFile, err)
+			continue
+		}
+
+		if certSigner, err := sshCertSigner(signer, identityFile, ""); err == nil {
+			signer = certSigner
+		} else {
+			log.Debugf("Skipping certificate of identity file %s: %v", identityFile, err)
+		}
+		signers = append(signers, signer)
+	}
+
+	return signers, nil
+}
+
+// keyboardInteractive answers server questions with configured password,
+// or asks user when running interactively.
+func (d Destination) keyboardInteractive(prompt PromptFunc) ssh.AuthMethod {
+	return ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i, question := range questions {
+			switch {
+			case !d.Password.IsZero():
+				password, err := d.Password.Resolve()
+				if err != nil {
+					return nil, err
+				}
+				answers[i] = password
+			case prompt != nil:
+				answer, err := prompt(question)
+				if err != nil {
+					return nil, err
+				}
+				answers[i] = answer
+			default:
+				return nil, errors.New("keyboard-interactive authentication requires password")
+			}
+		}
+
+		return answers, nil
+	})
+}
+
+func Addr(host string, port *int) string {
+	realPort := 22
+	if port != nil {
+		realPort = *port
+	}
+
+	return fmt.Sprintf("%s:%d", host, realPort)
+}
+
+func sshClientConfig(dest Destination, agentClient agent.ExtendedAgent, prompt PromptFunc) (*ssh.ClientConfig, error) {
+	hostKeyCallback, err := HostKeyCallback(dest)
+	if err != nil {
+		return nil, Error{err, "known_hosts"}
+	}
+
+	signers, err := dest.signers(agentClient, prompt)
+	if err != nil {
+		return nil, Error{err, "private_key"}
+	}
+
+	// client tries every method type only once, all keys must be in one
+	var authMethods []ssh.AuthMethod
+	if len(signers) > 0 {
+		authMethods = append(authMethods, ssh.PublicKeys(signers...))
+	}
+
+	// password is resolved only when server asks for it
+	if !dest.Password.IsZero() {
+		authMethods = append(authMethods, ssh.PasswordCallback(dest.Password.Resolve))
+	}
+
+	authMethods = append(authMethods, dest.keyboardInteractive(prompt))
+
+	return &ssh.ClientConfig{
+		User:            dest.User,
+		Auth:            authMethods,
+		HostKeyCallback: hostKeyCallback,
+	}, nil
+}
+
+// Dial connects to destination through its jump hosts. Caller owns returned
+// client, unlike clients of Pool.
+func Dial(ctx context.Context, dest Destination, prompt PromptFunc) (*ssh.Client, error) {
+	return DialChain(ctx, dest.Resolve().Hops(), prompt)
+}
+
+// DialChain connects to the first hop and then each next hop through the
+// previous one. Closing returned client closes the whole chain.
+func DialChain(ctx context.Context, hops []Destination, prompt PromptFunc) (*ssh.Client, error) {
+	agentClient, closeAgent := connectSshAgent()
+	defer closeAgent()
+
+	var client *ssh.Client
+	for _, hop := range hops {
+		next, err := dialSshVia(ctx, client, hop, agentClient, prompt)
+		if err != nil {
+			if client != nil {
+				client.Close()
+			}
+			return nil, err
+		}
+
+		if client != nil {
+			via := client
+			go func() {
+				next.Wait()
+				via.Close()
+			}()
+		}
+		client = next
+	}
+
+	return client, nil
+}
+
+func dialSshVia(ctx context.Context, via *ssh.Client, dest Destination, agentClient agent.ExtendedAgent, prompt PromptFunc) (*ssh.Client, error) {
+	config, err := sshClientConfig(dest, agentClient, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	var conn net.Conn
+	if via == nil {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", dest.Addr())
+		if err != nil {
+			return nil, sshDialError(err)
+		}
+	} else {
+		conn, err = via.DialContext(ctx, "tcp", dest.Addr())
+		if err != nil {
+			return nil, Error{fmt.Errorf("couldnt reach %s from jump host, %s", dest.Addr(), err), "ssh_jump"}
+		}
+	}
+
+	// handshake does not take context, cancellation closes the connection
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, dest.Addr(), config)
+	if !stop() {
+		if err == nil {
+			clientConn.Close()
+		}
+		return nil, ctx.Err()
+	}
+
+	if err != nil {
+		conn.Close()
+		return nil, sshDialError(err)
+	}
+
+	return ssh.NewClient(clientConn, chans, reqs), nil
+}
+
+func sshDialError(err error) error {
+	var unknownErr UnknownHostKeyError
+	if errors.As(err, &unknownErr) {
+		return Error{unknownErr, "unknown_host_key"}
+	}
+
+	var changedErr ChangedHostKeyError
+	if errors.As(err, &changedErr) {
+		return Error{changedErr, "host_key_changed"}
+	}
+
+	return Error{fmt.Errorf("couldnt dial ssh, %s", err), "ssh_dial"}
+}
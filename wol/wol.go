@@ -0,0 +1,55 @@
+// Package wol wakes machines by sending Wake-on-LAN magic packets.
+package wol
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/linde12/gowol"
+)
+
+// Address is broadcast address to which magic packet is sent.
+type Address struct {
+	Ip   string
+	Port int
+}
+
+// Error carries a short machine readable reason of failure, used as metric
+// label.
+type Error struct {
+	Err    error
+	Reason string
+}
+
+func (e Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
+// Send sends magic packet for mac to given addresses, 255.255.255.255 is used
+// when there are none.
+func Send(ctx context.Context, mac string, addresses []Address) error {
+	packet, err := gowol.NewMagicPacket(mac)
+	if err != nil {
+		return Error{err, "invalid_mac"}
+	}
+
+	if len(addresses) == 0 {
+		addresses = []Address{{"255.255.255.255", 7}, {"255.255.255.255", 9}}
+	}
+
+	for _, address := range addresses {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := packet.SendPort(address.Ip, strconv.Itoa(address.Port)); err != nil {
+			return Error{err, "send"}
+		}
+	}
+
+	return nil
+}
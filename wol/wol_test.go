@@ -0,0 +1,87 @@
+package wol
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+const testMac = "aa:bb:cc:dd:ee:ff"
+
+func listenUdp(t *testing.T) *net.UDPConn {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("couldnt listen, %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+
+	return conn
+}
+
+// countPackets returns number of magic packets received until no other
+// arrives for a while.
+func countPackets(t *testing.T, conn *net.UDPConn) int {
+	t.Helper()
+
+	count := 0
+	buf := make([]byte, 1024)
+	for {
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, err := conn.Read(buf)
+		if err != nil {
+			return count
+		}
+		// 6 bytes of 0xff followed by 16 repetitions of mac
+		if n != 102 {
+			t.Errorf("received %d bytes, expected magic packet of 102 bytes", n)
+		}
+		count++
+	}
+}
+
+func TestSendToEveryAddress(t *testing.T) {
+	tests := []struct {
+		name      string
+		listeners int
+	}{
+		{"single address", 1},
+		{"multiple addresses", 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var conns []*net.UDPConn
+			var addresses []Address
+			for i := 0; i < test.listeners; i++ {
+				conn := listenUdp(t)
+				conns = append(conns, conn)
+				addresses = append(addresses, Address{"127.0.0.1", conn.LocalAddr().(*net.UDPAddr).Port})
+			}
+
+			if err := Send(context.Background(), testMac, addresses); err != nil {
+				t.Fatalf("send failed: %v", err)
+			}
+
+			for i, conn := range conns {
+				if count := countPackets(t, conn); count != 1 {
+					t.Errorf("address %d received %d packets, expected 1", i, count)
+				}
+			}
+		})
+	}
+}
+
+func TestSendInvalidMac(t *testing.T) {
+	err := Send(context.Background(), "not-a-mac", []Address{{"127.0.0.1", 9}})
+
+	var wolErr Error
+	if !errors.As(err, &wolErr) || wolErr.Reason != "invalid_mac" {
+		t.Errorf("send with invalid mac returned %v, expected invalid_mac error", err)
+	}
+}